@@ -0,0 +1,49 @@
+package iradix
+
+const (
+	// arenaNodes is the number of nodes allocated at once by the arena.
+	arenaNodes = 256
+
+	// arenaEdges is the number of edges allocated at once by the arena.
+	arenaEdges = 2048
+
+	// maxArenaEdges is the maximum capacity of the edge list allocated from the arena chunk,
+	// longer lists are allocated separately not to waste the chunk.
+	maxArenaEdges = arenaEdges / 16
+)
+
+// arena allocates nodes and edge lists in chunks, so large transactions don't allocate every node
+// separately. Only the number of allocations is reduced: nodes contain pointers, so GC scans the chunks
+// like separately allocated nodes. Chunks are never reused, so the memory is reclaimed by GC when none of
+// the nodes allocated from the chunk is reachable. It means the committed tree is safe to retain
+// independently of the transaction, at the cost of keeping the whole chunk alive: single node pins
+// the chunk of arenaNodes nodes, and single edge list pins the chunk of arenaEdges edges.
+// Nil arena allocates everything separately.
+type arena[T any] struct {
+	nodes []Node[T]
+	edges []edge[T]
+}
+
+func (a *arena[T]) newNode() *Node[T] {
+	if a == nil {
+		return &Node[T]{}
+	}
+	if len(a.nodes) == 0 {
+		a.nodes = make([]Node[T], arenaNodes)
+	}
+	n := &a.nodes[0]
+	a.nodes = a.nodes[1:]
+	return n
+}
+
+func (a *arena[T]) makeEdges(length, capacity int) []edge[T] {
+	if a == nil || capacity > maxArenaEdges {
+		return make([]edge[T], length, capacity)
+	}
+	if len(a.edges) < capacity {
+		a.edges = make([]edge[T], arenaEdges)
+	}
+	es := a.edges[:length:capacity]
+	a.edges = a.edges[capacity:]
+	return es
+}
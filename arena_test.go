@@ -0,0 +1,86 @@
+package iradix
+
+import (
+	"fmt"
+	mathrand "math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArenaTxn(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+
+	r, ar := New[int](), New[int]()
+	var versions, arenaVersions []*Node[int]
+	for range 20 {
+		txn, arenaTxn := NewTxn(r), NewArenaTxn(ar)
+		for range 1000 {
+			k := []byte(randString(rand))
+			if rand.Intn(3) == 0 {
+				require.Equal(t, txn.Delete(k), arenaTxn.Delete(k))
+				continue
+			}
+			v := lo.ToPtr(rand.Int())
+			require.Equal(t, txn.Insert(k, v), arenaTxn.Insert(k, v))
+		}
+		r, ar = txn.Commit(), arenaTxn.Commit()
+		require.NoError(t, ar.Validate())
+		require.Equal(t, CopyTreeValues(r), CopyTreeValues(ar))
+
+		versions = append(versions, CopyTreeValues(r))
+		arenaVersions = append(arenaVersions, ar)
+	}
+
+	// Transactions must not modify nodes allocated for previous versions.
+	runtime.GC()
+	for i, v := range versions {
+		require.Equal(t, v, CopyTreeValues(arenaVersions[i]))
+	}
+}
+
+func TestArenaTxnClone(t *testing.T) {
+	txn := NewArenaTxn(New[int]())
+	txn.Insert([]byte("foo"), lo.ToPtr(1))
+	t2 := txn.Clone()
+
+	for i := range 100 {
+		txn.Insert([]byte(fmt.Sprintf("a%d", i)), lo.ToPtr(i))
+		t2.Insert([]byte(fmt.Sprintf("b%d", i)), lo.ToPtr(i))
+	}
+	r1, r2 := txn.Commit(), t2.Commit()
+
+	require.Equal(t, 101, len(collect(t, r1)))
+	require.Equal(t, 101, len(collect(t, r2)))
+	require.Nil(t, r1.Get([]byte("b1")))
+	require.Nil(t, r2.Get([]byte("a1")))
+	require.Equal(t, 1, *r2.Get([]byte("foo")))
+}
+
+func BenchmarkHugeTxn(b *testing.B) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	keys := make([][]byte, 200_000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%016x", rand.Uint64()))
+	}
+
+	for _, tc := range []struct {
+		name   string
+		newTxn func(root *Node[int]) *Txn[int]
+	}{
+		{name: "Heap", newTxn: NewTxn[int]},
+		{name: "Arena", newTxn: NewArenaTxn[int]},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				txn := tc.newTxn(New[int]())
+				for i, k := range keys {
+					txn.Set(k, i)
+				}
+			}
+		})
+	}
+}
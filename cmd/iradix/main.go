@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/outofforest/iradix"
+)
+
+const usage = `Usage: iradix <command> [flags] [arguments]
+
+Snapshot files are the ones produced by iradix.WriteSnapshot, streams are the ones produced by
+iradix.Export. Snapshots are mapped into memory instead of being decoded onto the heap. Keys and values
+are raw bytes, printed as quoted strings, or hex strings if -hex is set.
+
+Commands:
+  get [-hex] <snapshot> <key>                      print the value stored under the key
+  scan [-hex] [-prefix p] <snapshot>               print key/value pairs having the prefix
+  range [-hex] [-from k] [-to k] <snapshot>        print key/value pairs with keys in range [from, to)
+  count [-hex] [-prefix p] <snapshot>              print the number of keys having the prefix
+  stats <snapshot>                                 print statistics of the tree
+  validate <snapshot>                              verify invariants of the tree
+  dump [-dot] <snapshot>                           print the structure of the tree as text or Graphviz graph
+  diff [-hex] <snapshot a> <snapshot b>            print changes transforming tree a into tree b
+  export [-hex] [-prefix p] [-o file] <snapshot>   write sorted key/value stream, to stdout by default
+  import [-i file] <snapshot>                      build snapshot from sorted key/value stream, read from stdin by default
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+
+	// Every command registers only the flags it uses, so the ones passed by mistake are reported.
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	hexFlag := func() *bool {
+		return fs.Bool("hex", false, "keys and values are hex encoded")
+	}
+	prefixFlag := func() *string {
+		return fs.String("prefix", "", "key prefix")
+	}
+
+	var hexFormat *bool
+	var exec func(c cli) error
+	switch cmd {
+	case "get":
+		hexFormat = hexFlag()
+		exec = cli.get
+	case "scan":
+		hexFormat = hexFlag()
+		prefix := prefixFlag()
+		exec = func(c cli) error { return c.scan(*prefix) }
+	case "range":
+		hexFormat = hexFlag()
+		from := fs.String("from", "", "first key of the range")
+		to := fs.String("to", "", "key ending the range, excluded")
+		exec = func(c cli) error { return c.scanRange(*from, *to) }
+	case "count":
+		hexFormat = hexFlag()
+		prefix := prefixFlag()
+		exec = func(c cli) error { return c.count(*prefix) }
+	case "stats":
+		exec = cli.stats
+	case "validate":
+		exec = cli.validate
+	case "dump":
+		dot := fs.Bool("dot", false, "produce Graphviz graph")
+		exec = func(c cli) error { return c.dump(*dot) }
+	case "diff":
+		hexFormat = hexFlag()
+		exec = cli.diff
+	case "export":
+		hexFormat = hexFlag()
+		prefix := prefixFlag()
+		output := fs.String("o", "", "output file")
+		exec = func(c cli) error { return c.export(*prefix, *output) }
+	case "import":
+		input := fs.String("i", "", "input file")
+		exec = func(c cli) error { return c.importStream(stdin, *input) }
+	default:
+		return fmt.Errorf("unknown command %q\n\n%s", cmd, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w\n\n%s", err, usage)
+	}
+
+	c := cli{
+		args: fs.Args(),
+		hex:  hexFormat != nil && *hexFormat,
+		out:  bufio.NewWriter(stdout),
+	}
+	if err := exec(c); err != nil {
+		return err
+	}
+	return c.out.Flush()
+}
+
+type cli struct {
+	args []string
+	hex  bool
+	out  *bufio.Writer
+}
+
+func (c cli) get() error {
+	if err := c.expectArgs(2); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	k, err := c.parse(c.args[1])
+	if err != nil {
+		return err
+	}
+	v := tree.Get(k)
+	if v == nil {
+		return fmt.Errorf("key %s does not exist", c.format(k))
+	}
+	fmt.Fprintln(c.out, c.format(*v))
+	return nil
+}
+
+func (c cli) scan(prefix string) error {
+	p, err := c.parse(prefix)
+	if err != nil {
+		return err
+	}
+	return c.walk(p, nil, nil, c.printPair)
+}
+
+func (c cli) scanRange(from, to string) error {
+	f, err := c.parse(from)
+	if err != nil {
+		return err
+	}
+	t, err := c.parse(to)
+	if err != nil {
+		return err
+	}
+	return c.walk(nil, f, t, c.printPair)
+}
+
+func (c cli) count(prefix string) error {
+	p, err := c.parse(prefix)
+	if err != nil {
+		return err
+	}
+	var count int
+	err = c.walk(p, nil, nil, func(k, v []byte) {
+		count++
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, count)
+	return nil
+}
+
+func (c cli) stats() error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	root := tree.Root()
+	s := root.Stats()
+	fmt.Fprintf(c.out, "nodes:          %d\n", s.Nodes)
+	fmt.Fprintf(c.out, "leaves:         %d\n", s.Leaves)
+	fmt.Fprintf(c.out, "inner nodes:    %d\n", s.InnerNodes)
+	fmt.Fprintf(c.out, "values:         %d\n", s.Values)
+	fmt.Fprintf(c.out, "bytes:          %d\n", s.Bytes)
+	printHistogram(c.out, "fanout:", s.Fanout)
+	printHistogram(c.out, "prefix lengths:", s.PrefixLengths)
+	printHistogram(c.out, "depths:", s.Depths)
+	return nil
+}
+
+func (c cli) validate() error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	root := tree.Root()
+	if err := root.Validate(); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.out, "ok")
+	return nil
+}
+
+func (c cli) dump(dot bool) error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	root := tree.Root()
+	if dot {
+		return root.DumpDOT(c.out)
+	}
+	return root.DumpText(c.out)
+}
+
+func (c cli) diff() error {
+	if err := c.expectArgs(2); err != nil {
+		return err
+	}
+	a, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+	b, err := openSnapshot(c.args[1])
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	// Trees are loaded from different files so they don't share anything and diff reports all the keys.
+	// Unchanged ones are filtered out by comparing values.
+	for _, ch := range iradix.Diff(a.Root(), b.Root()) {
+		switch {
+		case ch.Old == nil:
+			fmt.Fprintf(c.out, "+ %s %s\n", c.format(ch.Key), c.format(*ch.New))
+		case ch.New == nil:
+			fmt.Fprintf(c.out, "- %s %s\n", c.format(ch.Key), c.format(*ch.Old))
+		case !bytes.Equal(*ch.Old, *ch.New):
+			fmt.Fprintf(c.out, "~ %s %s -> %s\n", c.format(ch.Key), c.format(*ch.Old), c.format(*ch.New))
+		}
+	}
+	return nil
+}
+
+func (c cli) export(prefix, output string) error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+	p, err := c.parse(prefix)
+	if err != nil {
+		return err
+	}
+	it := tree.Iterator()
+	it.SeekPrefix(p)
+	if output == "" {
+		return iradix.ExportMapped(c.out, it, mappedCodec{})
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := iradix.ExportMapped(f, it, mappedCodec{}); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c cli) importStream(stdin io.Reader, input string) error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	r := stdin
+	if input != "" {
+		f, err := os.Open(input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	root, err := iradix.Import(r, iradix.RawCodec{})
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(c.args[0])
+	if err != nil {
+		return err
+	}
+	if err := iradix.WriteSnapshot(f, root, iradix.RawCodec{}); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// walk calls fn for keys having the prefix in range [from, to). Nil to means there is no upper bound.
+func (c cli) walk(prefix, from, to []byte, fn func(k, v []byte)) error {
+	if err := c.expectArgs(1); err != nil {
+		return err
+	}
+	tree, err := openSnapshot(c.args[0])
+	if err != nil {
+		return err
+	}
+	defer tree.Close()
+
+	it := tree.Iterator()
+	it.SeekPrefix(prefix)
+	if from != nil {
+		it.SeekLowerBound(from)
+	}
+	for k, v := it.NextKey(); v != nil; k, v = it.NextKey() {
+		if to != nil && bytes.Compare(k, to) >= 0 {
+			break
+		}
+		fn(k, *v)
+	}
+	return nil
+}
+
+func (c cli) printPair(k, v []byte) {
+	fmt.Fprintf(c.out, "%s %s\n", c.format(k), c.format(v))
+}
+
+func (c cli) expectArgs(n int) error {
+	if len(c.args) != n {
+		return fmt.Errorf("expected %d arguments, got %d\n\n%s", n, len(c.args), usage)
+	}
+	return nil
+}
+
+func (c cli) parse(s string) ([]byte, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !c.hex {
+		return []byte(s), nil
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex string %q: %w", s, err)
+	}
+	return b, nil
+}
+
+func (c cli) format(b []byte) string {
+	if c.hex {
+		return hex.EncodeToString(b)
+	}
+	return strconv.Quote(string(b))
+}
+
+// mappedCodec decodes values without copying them, so they point to the mapping and remain valid
+// until the tree is closed.
+type mappedCodec struct {
+	iradix.RawCodec
+}
+
+// Decode returns data.
+func (mappedCodec) Decode(data []byte) (*[]byte, error) {
+	return &data, nil
+}
+
+// openSnapshot maps the snapshot file. Tree must be closed when it is not needed anymore.
+func openSnapshot(file string) (*iradix.MappedTree[[]byte], error) {
+	tree, err := iradix.OpenMappedTree[[]byte](file, mappedCodec{})
+	if err != nil {
+		return nil, fmt.Errorf("opening snapshot %s failed: %w", file, err)
+	}
+	return tree, nil
+}
+
+func printHistogram(w io.Writer, title string, histogram []int) {
+	fmt.Fprintln(w, title)
+	for i, count := range histogram {
+		if count > 0 {
+			fmt.Fprintf(w, "  %4d: %d\n", i, count)
+		}
+	}
+}
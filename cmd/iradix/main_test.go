@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/outofforest/iradix"
+)
+
+func TestCLI(t *testing.T) {
+	dir := t.TempDir()
+	fileA := writeSnapshot(t, dir, "a", map[string]string{
+		"aa":  "1",
+		"ab":  "2",
+		"abc": "3",
+		"b":   "4",
+	})
+	fileB := writeSnapshot(t, dir, "b", map[string]string{
+		"aa":  "1",
+		"abc": "5",
+		"b":   "4",
+		"c":   "6",
+	})
+
+	requireOutput(t, "\"2\"\n", "get", fileA, "ab")
+	requireOutput(t, "33\n", "get", "-hex", fileA, "616263")
+	requireOutput(t, "\"ab\" \"2\"\n\"abc\" \"3\"\n", "scan", "-prefix", "ab", fileA)
+	requireOutput(t, "\"ab\" \"2\"\n\"abc\" \"3\"\n", "range", "-from", "ab", "-to", "b", fileA)
+	requireOutput(t, "\"abc\" \"3\"\n\"b\" \"4\"\n", "range", "-from", "abc", fileA)
+	requireOutput(t, "\"aa\" \"1\"\n\"ab\" \"2\"\n", "range", "-to", "abc", fileA)
+	requireOutput(t, "4\n", "count", fileA)
+	requireOutput(t, "2\n", "count", "-prefix", "ab", fileA)
+	requireOutput(t, "ok\n", "validate", fileA)
+
+	// Keys are not valid UTF-8.
+	fileBin := writeSnapshot(t, dir, "bin", map[string]string{
+		"\x00":     "1",
+		"\xff":     "2",
+		"\xff\x00": "3",
+		"\xff\xff": "4",
+	})
+	requireOutput(t, "ff 32\nff00 33\n", "range", "-hex", "-from", "ff", "-to", "ff01", fileBin)
+	requireOutput(t, "ff00 33\nffff 34\n", "range", "-hex", "-from", "ff00", fileBin)
+	requireOutput(t, "ff 32\nff00 33\nffff 34\n", "scan", "-hex", "-prefix", "ff", fileBin)
+	requireOutput(t, "1\n", "count", "-hex", "-prefix", "ffff", fileBin)
+	requireOutput(t, "- \"ab\" \"2\"\n~ \"abc\" \"3\" -> \"5\"\n+ \"c\" \"6\"\n", "diff", fileA, fileB)
+
+	_, err := execute(nil, "get", fileA, "ac")
+	require.Error(t, err)
+	_, err = execute(nil, "unknown")
+	require.Error(t, err)
+	_, err = execute(nil, "get", fileA)
+	require.Error(t, err)
+	_, err = execute(nil, "get", "-prefix", "a", fileA, "ab")
+	require.ErrorContains(t, err, "flag provided but not defined: -prefix")
+	_, err = execute(nil, "stats", "-o", filepath.Join(dir, "out"), fileA)
+	require.ErrorContains(t, err, "flag provided but not defined: -o")
+
+	stats, err := execute(nil, "stats", fileA)
+	require.NoError(t, err)
+	require.Contains(t, stats, "values:         4\n")
+
+	dump, err := execute(nil, "dump", "-dot", fileA)
+	require.NoError(t, err)
+	require.Contains(t, dump, "digraph iradix {")
+
+	stream, err := execute(nil, "export", "-prefix", "ab", fileA)
+	require.NoError(t, err)
+	fileC := filepath.Join(dir, "c")
+	_, err = execute(bytes.NewBufferString(stream), "import", fileC)
+	require.NoError(t, err)
+	requireOutput(t, "\"ab\" \"2\"\n\"abc\" \"3\"\n", "scan", fileC)
+
+	streamFile := filepath.Join(dir, "stream")
+	_, err = execute(nil, "export", "-o", streamFile, fileB)
+	require.NoError(t, err)
+	_, err = execute(nil, "import", "-i", streamFile, fileC)
+	require.NoError(t, err)
+	requireOutput(t, "", "diff", fileB, fileC)
+}
+
+func writeSnapshot(t *testing.T, dir, name string, values map[string]string) string {
+	txn := iradix.NewTxn(iradix.New[[]byte]())
+	for k, v := range values {
+		txn.Set([]byte(k), []byte(v))
+	}
+
+	file := filepath.Join(dir, name)
+	f, err := os.Create(file)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, iradix.WriteSnapshot(f, txn.Commit(), iradix.RawCodec{}))
+	return file
+}
+
+func execute(stdin *bytes.Buffer, args ...string) (string, error) {
+	if stdin == nil {
+		stdin = &bytes.Buffer{}
+	}
+	out := &bytes.Buffer{}
+	err := run(args, stdin, out)
+	return out.String(), err
+}
+
+func requireOutput(t *testing.T, expected string, args ...string) {
+	out, err := execute(nil, args...)
+	require.NoError(t, err)
+	require.Equal(t, expected, out)
+}
@@ -0,0 +1,24 @@
+package iradix
+
+// ValueCodec converts values stored in the tree to and from their binary representation.
+type ValueCodec[T any] interface {
+	// Encode appends binary representation of v to buf and returns the extended buffer.
+	Encode(buf []byte, v *T) []byte
+
+	// Decode decodes value from data. Implementation must not retain data.
+	Decode(data []byte) (*T, error)
+}
+
+// RawCodec is the codec for trees storing raw byte slices.
+type RawCodec struct{}
+
+// Encode appends value to buf.
+func (RawCodec) Encode(buf []byte, v *[]byte) []byte {
+	return append(buf, *v...)
+}
+
+// Decode returns a copy of data.
+func (RawCodec) Decode(data []byte) (*[]byte, error) {
+	v := copyPrefix(data)
+	return &v, nil
+}
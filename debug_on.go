@@ -0,0 +1,6 @@
+//go:build iradixdebug
+
+package iradix
+
+// debug enables validation of trees committed by transactions.
+const debug = true
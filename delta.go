@@ -0,0 +1,87 @@
+package iradix
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// deltaHeaderSize is the size of the delta header, containing the hash of the base root after
+// the snapshot header.
+const deltaHeaderSize = headerSize + sha256.Size
+
+var deltaMagic = [4]byte{'I', 'R', 'X', 'D'}
+
+// ErrBaseMismatch is returned if delta is applied to a tree it was not created for.
+var ErrBaseMismatch = errors.New("delta does not match the base tree")
+
+// WriteDelta writes the nodes of current tree which are newer than the base tree to w.
+// Subtrees shared with base are stored as references to the base nodes. Base is identified by its revision
+// and the hash computed using Encode method of the codec, stored in the header.
+func WriteDelta[T any](w io.Writer, base, current *Node[T], codec ValueCodec[T]) error {
+	e := newEncoder(w, codec)
+	if err := e.writeHeader(deltaMagic, base.revision); err != nil {
+		return err
+	}
+	baseHash := base.Hash(codec.Encode)
+	if err := e.write(baseHash[:]); err != nil {
+		return err
+	}
+	rootOffset, err := e.encodeDelta(current, base, nil)
+	if err != nil {
+		return err
+	}
+	return e.writeTrailer(rootOffset)
+}
+
+// ApplyDelta reads the delta written by WriteDelta and reconstructs the newer tree.
+// Returned tree shares all the unchanged subtrees with base. Hash of the base is computed using Encode method
+// of the codec, and hashes are cached in the nodes, so trees sharing nodes with base must not be hashed
+// with another hasher.
+func ApplyDelta[T any](base *Node[T], r io.Reader, codec ValueCodec[T]) (*Node[T], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	revision, rootOffset, err := openSnapshot(data, deltaMagic)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < deltaHeaderSize+trailerSize || rootOffset < deltaHeaderSize {
+		return nil, fmt.Errorf("invalid delta header: %w", ErrCorrupted)
+	}
+	if revision != base.revision {
+		return nil, fmt.Errorf("delta created for revision %d, base has revision %d: %w",
+			revision, base.revision, ErrBaseMismatch)
+	}
+	if Hash(data[headerSize:deltaHeaderSize]) != base.Hash(codec.Encode) {
+		return nil, fmt.Errorf("delta created for another tree at revision %d: %w", revision, ErrBaseMismatch)
+	}
+	d := &decoder[T]{
+		data:  data,
+		codec: codec,
+		base:  base,
+	}
+	return d.decode(rootOffset, uint64(len(data)))
+}
+
+func (e *encoder[T]) encodeDelta(n, base *Node[T], path []byte) (uint64, error) {
+	path = append(path, n.prefix...)
+
+	// Revision tells if the node might be shared with base, but the check is confirmed by looking
+	// the node up, because trees might have been derived from different transactions.
+	if n.revision <= base.revision && base.nodeAt(path) == n {
+		return e.writeBaseRef(path)
+	}
+
+	children := make([]uint64, 0, n.children().len())
+	for _, child := range n.children().all() {
+		offset, err := e.encodeDelta(child, base, path)
+		if err != nil {
+			return 0, err
+		}
+		children = append(children, offset)
+	}
+	return e.writeNode(n, children)
+}
@@ -0,0 +1,152 @@
+package iradix
+
+import (
+	"bytes"
+)
+
+// Change describes the modification of a single key.
+type Change[T any] struct {
+	Key []byte
+
+	// Old is the previous value of the key, nil if key has been inserted.
+	Old *T
+
+	// New is the new value of the key, nil if key has been deleted.
+	New *T
+}
+
+// Diff returns the changes transforming tree a into tree b, ordered by key.
+// Subtrees shared by both trees are skipped without visiting them, so comparing versions
+// derived from each other costs time proportional to the number of changed nodes.
+// Values are compared by identity, not content: every Insert or Set gives the value new identity,
+// so it is reported as changed even if it is equal to the previous one.
+func Diff[T any](a, b *Node[T]) []Change[T] {
+	var changes []Change[T]
+	_ = diff(a, b, func(k []byte, oldV, newV *T) error {
+		changes = append(changes, Change[T]{Key: copyPrefix(k), Old: oldV, New: newV})
+		return nil
+	})
+	return changes
+}
+
+// DiffPrefix works like Diff but returns only the changes of keys having the prefix.
+func DiffPrefix[T any](a, b *Node[T], prefix []byte) []Change[T] {
+	var changes []Change[T]
+	_ = diffPrefix(a, b, prefix, func(k []byte, oldV, newV *T) error {
+		changes = append(changes, Change[T]{Key: copyPrefix(k), Old: oldV, New: newV})
+		return nil
+	})
+	return changes
+}
+
+type diffItem[T any] struct {
+	// path is the full key of the node, including its prefix.
+	path []byte
+	node *Node[T]
+
+	// leaf means that item represents the value of the node only, without its children.
+	leaf bool
+}
+
+// diff calls fn for every key having different value in a and b, in key order.
+// Key passed to fn must not be modified.
+func diff[T any](a, b *Node[T], fn func(k []byte, oldV, newV *T) error) error {
+	return diffSubtrees(a, copyPrefix(a.prefix), b, copyPrefix(b.prefix), fn)
+}
+
+// diffPrefix works like diff but compares only the keys having the prefix.
+func diffPrefix[T any](a, b *Node[T], prefix []byte, fn func(k []byte, oldV, newV *T) error) error {
+	nodeA, pathA := prefixNode(a, prefix)
+	nodeB, pathB := prefixNode(b, prefix)
+	return diffSubtrees(nodeA, pathA, nodeB, pathB, fn)
+}
+
+// prefixNode returns the node of the subtree storing all the keys having the prefix, together with
+// its full key.
+func prefixNode[T any](root *Node[T], prefix []byte) (*Node[T], []byte) {
+	it := root.Iterator()
+	it.SeekPrefix(prefix)
+	if it.node == nil {
+		return nil, nil
+	}
+	return it.node, concatPrefixes(prefix, it.node.prefix[it.skip:])
+}
+
+// diffSubtrees works like diff but compares subtrees, pathA and pathB are the full keys of the nodes.
+func diffSubtrees[T any](a *Node[T], pathA []byte, b *Node[T], pathB []byte,
+	fn func(k []byte, oldV, newV *T) error,
+) error {
+	// Stacks keep items sorted by path, with the lowest one on top. All the keys stored in the subtree
+	// of an item are greater or equal to its path and lower than the path of the item below it.
+	var stackA, stackB []diffItem[T]
+	if a != nil {
+		stackA = append(stackA, diffItem[T]{path: pathA, node: a})
+	}
+	if b != nil {
+		stackB = append(stackB, diffItem[T]{path: pathB, node: b})
+	}
+
+	for len(stackA) > 0 || len(stackB) > 0 {
+		var itemA, itemB diffItem[T]
+		okA, okB := len(stackA) > 0, len(stackB) > 0
+		if okA {
+			itemA = stackA[len(stackA)-1]
+		}
+		if okB {
+			itemB = stackB[len(stackB)-1]
+		}
+
+		switch {
+		case okA && okB && itemA.node == itemB.node && itemA.leaf == itemB.leaf &&
+			bytes.Equal(itemA.path, itemB.path):
+			stackA = stackA[:len(stackA)-1]
+			stackB = stackB[:len(stackB)-1]
+		case okA && !itemA.leaf && (!okB || bytes.Compare(itemA.path, itemB.path) <= 0):
+			stackA = expandDiffItem(stackA)
+		case okB && !itemB.leaf && (!okA || bytes.Compare(itemB.path, itemA.path) <= 0):
+			stackB = expandDiffItem(stackB)
+		case okA && okB && itemA.leaf && itemB.leaf && bytes.Equal(itemA.path, itemB.path):
+			stackA = stackA[:len(stackA)-1]
+			stackB = stackB[:len(stackB)-1]
+			if itemA.node.valueID != itemB.node.valueID {
+				if err := fn(itemA.path, &itemA.node.value, &itemB.node.value); err != nil {
+					return err
+				}
+			}
+		case !okB || (okA && bytes.Compare(itemA.path, itemB.path) < 0):
+			stackA = stackA[:len(stackA)-1]
+			if err := fn(itemA.path, &itemA.node.value, nil); err != nil {
+				return err
+			}
+		default:
+			stackB = stackB[:len(stackB)-1]
+			if err := fn(itemB.path, nil, &itemB.node.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// expandDiffItem replaces the item on top of the stack with its value and children.
+func expandDiffItem[T any](stack []diffItem[T]) []diffItem[T] {
+	itm := stack[len(stack)-1]
+	stack = stack[:len(stack)-1]
+
+	es := itm.node.children()
+	for pos := es.prev(es.end()); pos >= 0; pos = es.prev(pos) {
+		child := es.at(pos).node
+		stack = append(stack, diffItem[T]{
+			path: concatPrefixes(itm.path, child.prefix),
+			node: child,
+		})
+	}
+	if itm.node.hasValue() {
+		stack = append(stack, diffItem[T]{
+			path: itm.path,
+			node: itm.node,
+			leaf: true,
+		})
+	}
+	return stack
+}
@@ -0,0 +1,69 @@
+package iradix
+
+import (
+	mathrand "math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	r := New[string]()
+	model := map[string]*string{}
+
+	for range 100 {
+		txn := NewTxn(r)
+		modelNext := map[string]*string{}
+		for k, v := range model {
+			modelNext[k] = v
+		}
+		for range rand.Intn(20) {
+			k := randString(rand)
+			if rand.Intn(3) == 0 {
+				txn.Delete([]byte(k))
+				delete(modelNext, k)
+				continue
+			}
+			v := randString(rand)
+			txn.Insert([]byte(k), &v)
+			modelNext[k] = &v
+		}
+		rNext := txn.Commit()
+
+		var expected []Change[string]
+		for k, v := range model {
+			if vNext, exists := modelNext[k]; !exists || v != vNext {
+				expected = append(expected, Change[string]{Key: []byte(k), Old: v, New: vNext})
+			}
+		}
+		for k, v := range modelNext {
+			if _, exists := model[k]; !exists {
+				expected = append(expected, Change[string]{Key: []byte(k), New: v})
+			}
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			return string(expected[i].Key) < string(expected[j].Key)
+		})
+
+		require.Equal(t, expected, Diff(r, rNext))
+
+		r = rNext
+		model = modelNext
+	}
+}
+
+func TestDiffUnrelatedTrees(t *testing.T) {
+	a := buildTree("a", "ab", "b")
+	b := buildTree("a", "abc", "b")
+
+	// Values are compared by pointers, so a and b are reported too.
+	changes := Diff(a, b)
+	require.Len(t, changes, 4)
+	require.Equal(t, []string{"a", "ab", "abc", "b"}, []string{
+		string(changes[0].Key), string(changes[1].Key), string(changes[2].Key), string(changes[3].Key),
+	})
+	require.Nil(t, changes[1].New)
+	require.Nil(t, changes[2].Old)
+}
@@ -0,0 +1,85 @@
+package iradix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DumpDOT writes the structure of the tree in Graphviz DOT format. Each node shows its prefix, revision
+// and whether it stores a value, edges are described by their labels. Nodes having one of the highlighted
+// revisions are filled, so it is visible which nodes have been created by the transaction.
+func (n *Node[T]) DumpDOT(w io.Writer, highlight ...uint64) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph iradix {")
+	fmt.Fprintln(bw, `	node [shape=box, fontname="monospace"];`)
+	var id int
+	n.dumpDOT(bw, &id, highlight)
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+func (n *Node[T]) dumpDOT(w io.Writer, id *int, highlight []uint64) int {
+	nodeID := *id
+	*id++
+
+	label := fmt.Sprintf("prefix %s\\nrevision %d", dotEscape(strconv.Quote(string(n.prefix))), n.revision)
+	if n.hasValue() {
+		label += "\\nvalue"
+	}
+	var style string
+	if slices.Contains(highlight, n.revision) {
+		style = ", style=filled, fillcolor=yellow"
+	}
+	fmt.Fprintf(w, "\tn%d [label=\"%s\"%s];\n", nodeID, label, style)
+
+	for label, child := range n.children().all() {
+		childID := child.dumpDOT(w, id, highlight)
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%s\"];\n", nodeID, childID, dotEscape(edgeLabel(label)))
+	}
+	return nodeID
+}
+
+// DumpText writes the structure of the tree as indented text, one node per line. Nodes having one of
+// the highlighted revisions are marked with the asterisk.
+func (n *Node[T]) DumpText(w io.Writer, highlight ...uint64) error {
+	bw := bufio.NewWriter(w)
+	n.dumpText(bw, "", "", highlight)
+	return bw.Flush()
+}
+
+func (n *Node[T]) dumpText(w io.Writer, indent, label string, highlight []uint64) {
+	var sb strings.Builder
+	sb.WriteString(indent)
+	if label != "" {
+		sb.WriteString(label)
+		sb.WriteString(" ")
+	}
+	fmt.Fprintf(&sb, "%q rev=%d", n.prefix, n.revision)
+	if n.hasValue() {
+		sb.WriteString(" value")
+	}
+	if slices.Contains(highlight, n.revision) {
+		sb.WriteString(" *")
+	}
+	fmt.Fprintln(w, sb.String())
+
+	for label, child := range n.children().all() {
+		child.dumpText(w, indent+"  ", edgeLabel(label), highlight)
+	}
+}
+
+// edgeLabel formats the label as the quoted character if it is printable, or the hex number otherwise.
+func edgeLabel(label byte) string {
+	if label >= 0x20 && label < 0x7f {
+		return strconv.QuoteRune(rune(label))
+	}
+	return fmt.Sprintf("%#02x", label)
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,49 @@
+package iradix
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpText(t *testing.T) {
+	r := buildTree("a", "ab", "ac\x00")
+	txn := NewTxn(r)
+	txn.Insert([]byte("b\""), lo.ToPtr(10))
+	r = txn.Commit()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.DumpText(buf, 2))
+	require.Equal(t, `"" rev=2 *
+  'a' "a" rev=1 value
+    'b' "b" rev=1 value
+    'c' "c\x00" rev=1 value
+  'b' "b\"" rev=2 value *
+`, buf.String())
+}
+
+func TestDumpDOT(t *testing.T) {
+	r := buildTree("a", "ab\\")
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, r.DumpDOT(buf, 1))
+	require.Equal(t, `digraph iradix {
+	node [shape=box, fontname="monospace"];
+	n0 [label="prefix \"\"\nrevision 1", style=filled, fillcolor=yellow];
+	n1 [label="prefix \"a\"\nrevision 1\nvalue", style=filled, fillcolor=yellow];
+	n2 [label="prefix \"b\\\\\"\nrevision 1\nvalue", style=filled, fillcolor=yellow];
+	n1 -> n2 [label="'b'"];
+	n0 -> n1 [label="'a'"];
+}
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, New[int]().DumpDOT(buf))
+	require.Equal(t, `digraph iradix {
+	node [shape=box, fontname="monospace"];
+	n0 [label="prefix \"\"\nrevision 0"];
+}
+`, buf.String())
+}
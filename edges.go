@@ -1,21 +1,302 @@
 package iradix
 
-import "sort"
+import "iter"
 
-type edges[T any] []edge[T]
+// layout is the representation of the node children, chosen based on their number.
+type layout uint8
 
-func (e edges[T]) Len() int {
-	return len(e)
+const (
+	// layoutSorted keeps up to 16 children in arrays sorted by label. Arrays start with capacity of 4
+	// and grow to 16, matching Node4 and Node16 of the adaptive radix tree.
+	layoutSorted layout = iota
+
+	// layout48 keeps up to 48 children in slots referenced by the 256-entry index of labels.
+	layout48
+
+	// layout256 keeps children in the 256-entry array indexed by label.
+	layout256
+)
+
+const (
+	maxSorted = 16
+	max48     = 48
+
+	// Layouts are shrunk only when the number of children drops well below the capacity of the smaller
+	// layout, so nodes alternating between adding and deleting children are not converted every time.
+	shrinkTo48     = 40
+	shrinkToSorted = 12
+
+	// endDense is the end position of the dense layouts.
+	endDense = 256
+)
+
+// edges keeps the children of the node in one of the layouts. Children are addressed by positions,
+// meaning of which depends on the layout: for sorted layout position is the index in the list, for the dense
+// ones it is the label. Positions of the consecutive children are obtained by next and prev. Position -1
+// precedes the first child and end() follows the last one.
+//
+// Most of the nodes have few children, so state of the dense layouts is kept behind the pointer, and sorted
+// layout costs nothing on top of the list.
+type edges[T any] struct {
+	// list keeps the edges. In sorted layout they are sorted by label, in layout48 they are stored in slots
+	// pointed by index, and in layout256 they are indexed by label, with nil node meaning there is no child.
+	list []edge[T]
+
+	// dense is set in layout48 and layout256.
+	dense *denseEdges
+}
+
+// denseEdges is the state of the dense layouts.
+type denseEdges struct {
+	layout layout
+	num    uint16
+
+	// index maps labels to slot numbers increased by one in layout48, zero means there is no child.
+	index [256]uint8
+}
+
+func (es *edges[T]) layout() layout {
+	if es.dense == nil {
+		return layoutSorted
+	}
+	return es.dense.layout
+}
+
+func (es *edges[T]) len() int {
+	if es.dense == nil {
+		return len(es.list)
+	}
+	return int(es.dense.num)
+}
+
+func (es *edges[T]) end() int {
+	if es.dense == nil {
+		return len(es.list)
+	}
+	return endDense
+}
+
+func (es *edges[T]) first() int {
+	return es.next(-1)
+}
+
+func (es *edges[T]) next(pos int) int {
+	if es.dense == nil {
+		return pos + 1
+	}
+	return es.nextDense(pos)
+}
+
+func (es *edges[T]) prev(pos int) int {
+	if es.dense == nil {
+		return pos - 1
+	}
+	return es.prevDense(pos)
+}
+
+func (es *edges[T]) nextDense(pos int) int {
+	for pos++; pos < endDense; pos++ {
+		if es.dense.layout == layout48 && es.dense.index[pos] != 0 ||
+			es.dense.layout == layout256 && es.list[pos].node != nil {
+			return pos
+		}
+	}
+	return endDense
+}
+
+func (es *edges[T]) prevDense(pos int) int {
+	for pos = min(pos, endDense) - 1; pos >= 0; pos-- {
+		if es.dense.layout == layout48 && es.dense.index[pos] != 0 ||
+			es.dense.layout == layout256 && es.list[pos].node != nil {
+			return pos
+		}
+	}
+	return -1
+}
+
+func (es *edges[T]) at(pos int) edge[T] {
+	if es.layout() == layout48 {
+		return es.list[es.dense.index[pos]-1]
+	}
+	return es.list[pos]
+}
+
+// ref returns the reference to the child at the position, used to replace it.
+func (es *edges[T]) ref(pos int) **Node[T] {
+	if es.layout() == layout48 {
+		return &es.list[es.dense.index[pos]-1].node
+	}
+	return &es.list[pos].node
+}
+
+// all iterates over labels and children in order.
+func (es *edges[T]) all() iter.Seq2[byte, *Node[T]] {
+	return func(yield func(byte, *Node[T]) bool) {
+		for pos, end := es.first(), es.end(); pos < end; pos = es.next(pos) {
+			e := es.at(pos)
+			if !yield(e.label, e.node) {
+				return
+			}
+		}
+	}
+}
+
+// find returns the position of the child with the label or -1 if there is no such child.
+func (es *edges[T]) find(label byte) int {
+	switch es.layout() {
+	case layoutSorted:
+		if idx := es.search(label); idx < len(es.list) && es.list[idx].label == label {
+			return idx
+		}
+	case layout48:
+		if es.dense.index[label] != 0 {
+			return int(label)
+		}
+	default:
+		if es.list[label].node != nil {
+			return int(label)
+		}
+	}
+	return -1
+}
+
+// lowerBound returns the position of the first child with label greater or equal to the one passed,
+// or end() if there is no such child.
+func (es *edges[T]) lowerBound(label byte) int {
+	if es.dense == nil {
+		return es.search(label)
+	}
+	return es.next(int(label) - 1)
+}
+
+// search returns the index of the first label greater or equal to the one passed in sorted layout.
+func (es *edges[T]) search(label byte) int {
+	// Define f(-1) == false and f(n) == true.
+	// Invariant: f(i-1) == false, f(j) == true.
+	i, j := 0, len(es.list)
+	for i < j {
+		h := int(uint(i+j) >> 1) // avoid overflow when computing h.
+		// i ≤ h < j
+		if es.list[h].label < label {
+			i = h + 1 // preserves f(i-1) == false
+		} else {
+			j = h // preserves f(j) == true
+		}
+	}
+	// i == j, f(i-1) == false, and f(j) (= f(i)) == true  =>  answer is i.
+	return i
+}
+
+func (es *edges[T]) add(e edge[T], a *arena[T]) {
+	switch es.layout() {
+	case layoutSorted:
+		if len(es.list) == maxSorted {
+			es.convert(layout48, a)
+			es.add(e, a)
+			return
+		}
+		if len(es.list) == cap(es.list) {
+			es.reserve(min(max(4, 4*cap(es.list)), maxSorted), a)
+		}
+		idx := es.search(e.label)
+		es.list = append(es.list, e)
+		if idx != len(es.list)-1 {
+			copy(es.list[idx+1:], es.list[idx:])
+			es.list[idx] = e
+		}
+		return
+	case layout48:
+		if es.dense.num == max48 {
+			es.convert(layout256, a)
+			es.add(e, a)
+			return
+		}
+		es.list = append(es.list, e)
+		es.dense.index[e.label] = uint8(len(es.list))
+	default:
+		es.list[e.label] = e
+	}
+	es.dense.num++
+}
+
+func (es *edges[T]) del(label byte, a *arena[T]) {
+	pos := es.find(label)
+	if pos < 0 {
+		return
+	}
+
+	switch es.layout() {
+	case layoutSorted:
+		copy(es.list[pos:], es.list[pos+1:])
+		es.list[len(es.list)-1] = edge[T]{}
+		es.list = es.list[:len(es.list)-1]
+	case layout48:
+		es.dense.num--
+		// Last slot is moved to the released one, so slots stay compact.
+		slot := es.dense.index[label] - 1
+		last := len(es.list) - 1
+		if int(slot) != last {
+			es.list[slot] = es.list[last]
+			es.dense.index[es.list[slot].label] = slot + 1
+		}
+		es.list[last] = edge[T]{}
+		es.list = es.list[:last]
+		es.dense.index[label] = 0
+		if es.dense.num <= shrinkToSorted {
+			es.convert(layoutSorted, a)
+		}
+	default:
+		es.dense.num--
+		es.list[label] = edge[T]{}
+		if es.dense.num <= shrinkTo48 {
+			es.convert(layout48, a)
+		}
+	}
 }
 
-func (e edges[T]) Less(i, j int) bool {
-	return e[i].label < e[j].label
+// reserve reallocates the list to have the capacity.
+func (es *edges[T]) reserve(capacity int, a *arena[T]) {
+	list := a.makeEdges(len(es.list), capacity)
+	copy(list, es.list)
+	es.list = list
 }
 
-func (e edges[T]) Swap(i, j int) {
-	e[i], e[j] = e[j], e[i]
+// convert changes the layout, keeping the children.
+func (es *edges[T]) convert(l layout, a *arena[T]) {
+	old := *es
+	*es = edges[T]{}
+	switch l {
+	case layoutSorted:
+		es.reserve(maxSorted, a)
+	case layout48:
+		es.reserve(max48, a)
+		es.dense = &denseEdges{layout: l}
+	default:
+		es.list = a.makeEdges(endDense, endDense)
+		es.dense = &denseEdges{layout: l}
+	}
+	for label, child := range old.all() {
+		es.add(edge[T]{label: label, node: child}, a)
+	}
 }
 
-func (e edges[T]) Sort() {
-	sort.Sort(e)
+// clone returns the copy of edges which might be modified without affecting the original ones.
+func (es *edges[T]) clone(a *arena[T]) edges[T] {
+	c := edges[T]{list: es.list[:len(es.list):len(es.list)]}
+	switch es.layout() {
+	case layoutSorted:
+		if len(es.list) > 0 {
+			// +2 is for possible new edges, to avoid slice growing later
+			c.reserve(min(len(es.list)+2, maxSorted), a)
+		}
+	case layout48:
+		c.reserve(max48, a)
+	default:
+		c.reserve(endDense, a)
+	}
+	if es.dense != nil {
+		dense := *es.dense
+		c.dense = &dense
+	}
+	return c
 }
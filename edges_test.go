@@ -0,0 +1,202 @@
+package iradix
+
+import (
+	"fmt"
+	"maps"
+	mathrand "math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func requireEdges(t *testing.T, es *edges[int], labels []byte) {
+	t.Helper()
+
+	require.Equal(t, len(labels), es.len())
+	var got []byte
+	for label, child := range es.all() {
+		require.Equal(t, int(label), child.value)
+		got = append(got, label)
+	}
+	require.Equal(t, string(labels), string(got))
+
+	var back []byte
+	for pos := es.prev(es.end()); pos >= 0; pos = es.prev(pos) {
+		back = append(back, es.at(pos).label)
+	}
+	require.Equal(t, string(lo.Reverse(slices.Clone(labels))), string(back))
+
+	for l := range 256 {
+		label := byte(l)
+		idx, found := slices.BinarySearch(labels, label)
+		pos := es.find(label)
+		require.Equal(t, found, pos >= 0)
+		if found {
+			require.Equal(t, label, es.at(pos).label)
+		}
+		pos = es.lowerBound(label)
+		if idx == len(labels) {
+			require.Equal(t, es.end(), pos)
+		} else {
+			require.Equal(t, labels[idx], es.at(pos).label)
+		}
+	}
+}
+
+func TestEdgesLayouts(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+
+	var es edges[int]
+	var labels []byte
+	for _, l := range rand.Perm(256) {
+		label := byte(l)
+		es.add(edge[int]{label: label, node: &Node[int]{value: l}}, nil)
+		labels = append(labels, label)
+		slices.Sort(labels)
+
+		switch {
+		case len(labels) <= maxSorted:
+			require.Equal(t, layoutSorted, es.layout())
+		case len(labels) <= max48:
+			require.Equal(t, layout48, es.layout())
+		default:
+			require.Equal(t, layout256, es.layout())
+		}
+		if len(labels)%8 == 0 {
+			requireEdges(t, &es, labels)
+
+			// Cloned edges must not be affected by modifications of the original ones.
+			c := es.clone(nil)
+			es.del(labels[0], nil)
+			es.add(edge[int]{label: labels[0], node: &Node[int]{value: int(labels[0])}}, nil)
+			requireEdges(t, &c, labels)
+		}
+	}
+	requireEdges(t, &es, labels)
+
+	for _, l := range rand.Perm(256) {
+		es.del(byte(l), nil)
+		labels = slices.DeleteFunc(labels, func(label byte) bool { return label == byte(l) })
+
+		switch {
+		case len(labels) <= shrinkToSorted:
+			require.Equal(t, layoutSorted, es.layout())
+		case len(labels) <= shrinkTo48:
+			require.Equal(t, layout48, es.layout())
+		}
+		if len(labels)%8 == 0 {
+			requireEdges(t, &es, labels)
+		}
+	}
+	require.Zero(t, es.len())
+}
+
+func TestDenseTree(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	randKey := func() string {
+		b := make([]byte, 1+rand.Intn(3))
+		for i := range b {
+			b[i] = byte(rand.Intn(256))
+		}
+		return string(b)
+	}
+
+	reference := map[string]string{}
+	r := New[string]()
+	for range 10 {
+		prev, prevReference := r, maps.Clone(reference)
+
+		txn := NewTxn(r)
+		for range 2000 {
+			k := randKey()
+			if rand.Intn(3) == 0 {
+				old := txn.Delete([]byte(k))
+				_, exists := reference[k]
+				require.Equal(t, exists, old != nil)
+				delete(reference, k)
+				continue
+			}
+			txn.Insert([]byte(k), lo.ToPtr(k))
+			reference[k] = k
+		}
+		r = txn.Commit()
+		require.NoError(t, r.Validate())
+
+		requireIteration(t, prev, prevReference)
+		requireIteration(t, r, reference)
+
+		keys := lo.Keys(reference)
+		sort.Strings(keys)
+		for range 100 {
+			bound := randKey()
+			it := r.Iterator()
+			it.SeekLowerBound([]byte(bound))
+			expected := keys[sort.SearchStrings(keys, bound):]
+			back := min(rand.Intn(10), len(keys)-len(expected))
+			it.Back(uint64(back))
+			require.Equal(t, keys[len(keys)-len(expected)-back:], iterateValues(it))
+
+			prefix := bound[:1]
+			it = r.Iterator()
+			it.SeekPrefix([]byte(prefix))
+			require.Equal(t, lo.Filter(keys, func(k string, _ int) bool {
+				return strings.HasPrefix(k, prefix)
+			}), iterateValues(it))
+		}
+	}
+}
+
+func requireIteration(t *testing.T, r *Node[string], reference map[string]string) {
+	keys := lo.Keys(reference)
+	sort.Strings(keys)
+	require.Equal(t, keys, iterateValues(r.Iterator()))
+	for _, k := range keys {
+		require.Equal(t, k, *r.Get([]byte(k)))
+	}
+}
+
+func iterateValues(it *Iterator[string]) []string {
+	values := []string{}
+	for v := it.Next(); v != nil; v = it.Next() {
+		values = append(values, *v)
+	}
+	return values
+}
+
+func BenchmarkDense(b *testing.B) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	keys := make([][]byte, 100000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("%c%c%c%c", rand.Intn(256), rand.Intn(256), rand.Intn(256), rand.Intn(256)))
+	}
+	txn := NewTxn(New[int]())
+	for i, k := range keys {
+		txn.Insert(k, lo.ToPtr(i))
+	}
+	r := txn.Commit()
+
+	b.Run("Get", func(b *testing.B) {
+		for i := range b.N {
+			r.Get(keys[i%len(keys)])
+		}
+	})
+	b.Run("Insert", func(b *testing.B) {
+		b.ReportAllocs()
+		txn := NewTxn(r)
+		v := lo.ToPtr(0)
+		for i := range b.N {
+			txn.Insert(keys[i%len(keys)], v)
+		}
+	})
+	b.Run("Iterate", func(b *testing.B) {
+		for range b.N {
+			it := r.Iterator()
+			for v := it.Next(); v != nil; v = it.Next() {
+			}
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package iradix
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxChunkSize limits the size of a single key or value read from a stream, so corrupted input
+// can't cause gigantic allocations.
+const maxChunkSize = 1 << 30
+
+// ErrUnsorted is returned when keys are not supplied in strictly increasing order.
+var ErrUnsorted = errors.New("keys are not sorted")
+
+// Export writes all the key/value pairs of the tree to w in key order. Each pair is encoded
+// as uvarint-prefixed key followed by uvarint-prefixed value.
+func Export[T any](w io.Writer, root *Node[T], codec ValueCodec[T]) error {
+	return ExportRange(w, root, nil, nil, codec)
+}
+
+// ExportPrefix writes the key/value pairs having the given prefix to w in key order.
+func ExportPrefix[T any](w io.Writer, root *Node[T], prefix []byte, codec ValueCodec[T]) error {
+	return ExportRange(w, root, prefix, prefixEnd(prefix), codec)
+}
+
+// ExportRange writes the key/value pairs with keys in range [from, to) to w in key order.
+// Nil to means there is no upper bound.
+func ExportRange[T any](w io.Writer, root *Node[T], from, to []byte, codec ValueCodec[T]) error {
+	return export(w, codec, func(fn func(k []byte, v *T) error) error {
+		return root.walk(from, to, fn)
+	})
+}
+
+// ExportMapped writes the key/value pairs returned by the iterator of the mapped tree to w in key order.
+// Iterator might be seeked first to export part of the tree.
+func ExportMapped[T any](w io.Writer, it *MappedIterator[T], codec ValueCodec[T]) error {
+	return export(w, codec, func(fn func(k []byte, v *T) error) error {
+		for k, v := it.NextKey(); v != nil; k, v = it.NextKey() {
+			if err := fn(k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func export[T any](w io.Writer, codec ValueCodec[T], walk func(fn func(k []byte, v *T) error) error) error {
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	err := walk(func(k []byte, v *T) error {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(k)))
+		buf = append(buf, k...)
+		valueStart := len(buf)
+		buf = codec.Encode(buf, v)
+		valueLen := len(buf) - valueStart
+
+		// Length of the value is known only after encoding so the value is encoded first and
+		// the prefix is written before it.
+		if _, err := bw.Write(buf[:valueStart]); err != nil {
+			return err
+		}
+		var lenBuf [binary.MaxVarintLen64]byte
+		if _, err := bw.Write(lenBuf[:binary.PutUvarint(lenBuf[:], uint64(valueLen))]); err != nil {
+			return err
+		}
+		_, err := bw.Write(buf[valueStart:])
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Import reads the stream produced by Export and builds a tree out of it using the Loader.
+func Import[T any](r io.Reader, codec ValueCodec[T]) (*Node[T], error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		bufReader := bufio.NewReader(r)
+		br = bufReader
+		r = bufReader
+	}
+
+	l := NewLoader[T]()
+	var buf []byte
+	for {
+		k, err := readChunk(r, br, nil)
+		if errors.Is(err, io.EOF) {
+			return l.Commit(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		buf, err = readChunk(r, br, buf[:0])
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		v, err := codec.Decode(buf)
+		if err != nil {
+			return nil, fmt.Errorf("decoding value of key %q failed: %w", k, err)
+		}
+		if err := l.Add(k, v); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Loader builds a tree out of key/value pairs supplied in strictly increasing key order.
+type Loader[T any] struct {
+	txn     *Txn[T]
+	lastKey []byte
+	empty   bool
+}
+
+// NewLoader creates new loader building an empty tree.
+func NewLoader[T any]() *Loader[T] {
+	return &Loader[T]{
+		txn:   NewTxn(New[T]()),
+		empty: true,
+	}
+}
+
+// Add adds the key/value pair to the tree. Key must be greater than the one passed previously.
+func (l *Loader[T]) Add(k []byte, v *T) error {
+	if !l.empty && bytes.Compare(k, l.lastKey) <= 0 {
+		return fmt.Errorf("key %q follows %q: %w", k, l.lastKey, ErrUnsorted)
+	}
+	l.empty = false
+	l.lastKey = append(l.lastKey[:0], k...)
+	l.txn.Insert(k, v)
+	return nil
+}
+
+// Commit returns the built tree.
+func (l *Loader[T]) Commit() *Node[T] {
+	return l.txn.Commit()
+}
+
+func readChunk(r io.Reader, br io.ByteReader, buf []byte) ([]byte, error) {
+	size, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if size > maxChunkSize {
+		return nil, fmt.Errorf("chunk of %d bytes exceeds the limit", size)
+	}
+	if uint64(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return buf, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// prefixEnd returns the smallest key greater than all the keys having the prefix.
+// Nil is returned if there is no such key.
+func prefixEnd(prefix []byte) []byte {
+	for i := len(prefix) - 1; i >= 0; i-- {
+		if prefix[i] != 0xff {
+			end := copyPrefix(prefix[:i+1])
+			end[i]++
+			return end
+		}
+	}
+	return nil
+}
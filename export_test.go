@@ -0,0 +1,154 @@
+package iradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type intCodec struct{}
+
+func (intCodec) Encode(buf []byte, v *int) []byte {
+	return binary.AppendVarint(buf, int64(*v))
+}
+
+func (intCodec) Decode(data []byte) (*int, error) {
+	v, n := binary.Varint(data)
+	if n <= 0 || n != len(data) {
+		return nil, errors.New("invalid varint")
+	}
+	return lo.ToPtr(int(v)), nil
+}
+
+func buildTree(keys ...string) *Node[int] {
+	txn := NewTxn(New[int]())
+	for i, k := range keys {
+		txn.Insert([]byte(k), lo.ToPtr(i))
+	}
+	return txn.Commit()
+}
+
+func collect(t *testing.T, r *Node[int]) map[string]int {
+	out := map[string]int{}
+	require.NoError(t, r.walk(nil, nil, func(k []byte, v *int) error {
+		out[string(k)] = *v
+		return nil
+	}))
+	return out
+}
+
+func TestExportImport(t *testing.T) {
+	keys := []string{"", "a", "ab", "abc", "b", "ba", "foo/bar", "foo/baz", "zip", "\xff", "\xff\xff"}
+	r := buildTree(keys...)
+	m, err := OpenMappedTree[int](tempSnapshotFile[int](t, r, intCodec{}), intCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	cases := []struct {
+		name   string
+		export func(buf *bytes.Buffer) error
+		want   []string
+	}{
+		{
+			name:   "all",
+			export: func(buf *bytes.Buffer) error { return Export[int](buf, r, intCodec{}) },
+			want:   keys,
+		},
+		{
+			name:   "prefix",
+			export: func(buf *bytes.Buffer) error { return ExportPrefix[int](buf, r, []byte("a"), intCodec{}) },
+			want:   []string{"a", "ab", "abc"},
+		},
+		{
+			name:   "prefix ff",
+			export: func(buf *bytes.Buffer) error { return ExportPrefix[int](buf, r, []byte("\xff"), intCodec{}) },
+			want:   []string{"\xff", "\xff\xff"},
+		},
+		{
+			name: "range",
+			export: func(buf *bytes.Buffer) error {
+				return ExportRange[int](buf, r, []byte("ab"), []byte("foo/baz"), intCodec{})
+			},
+			want: []string{"ab", "abc", "b", "ba", "foo/bar"},
+		},
+		{
+			name: "range inside prefix",
+			export: func(buf *bytes.Buffer) error {
+				return ExportRange[int](buf, r, []byte("aa"), []byte("b"), intCodec{})
+			},
+			want: []string{"ab", "abc"},
+		},
+		{
+			name:   "mapped",
+			export: func(buf *bytes.Buffer) error { return ExportMapped[int](buf, m.Iterator(), intCodec{}) },
+			want:   keys,
+		},
+		{
+			name: "mapped prefix",
+			export: func(buf *bytes.Buffer) error {
+				it := m.Iterator()
+				it.SeekPrefix([]byte("foo/"))
+				return ExportMapped[int](buf, it, intCodec{})
+			},
+			want: []string{"foo/bar", "foo/baz"},
+		},
+		{
+			name: "mapped lower bound",
+			export: func(buf *bytes.Buffer) error {
+				it := m.Iterator()
+				it.SeekLowerBound([]byte("b"))
+				return ExportMapped[int](buf, it, intCodec{})
+			},
+			want: []string{"b", "ba", "foo/bar", "foo/baz", "zip", "\xff", "\xff\xff"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, tc.export(buf))
+
+			// Verify raw format of the stream.
+			raw := bytes.NewReader(buf.Bytes())
+			var gotKeys []string
+			for raw.Len() > 0 {
+				k, err := readChunk(raw, raw, nil)
+				require.NoError(t, err)
+				_, err = readChunk(raw, raw, nil)
+				require.NoError(t, err)
+				gotKeys = append(gotKeys, string(k))
+			}
+			require.Equal(t, tc.want, gotKeys)
+
+			r2, err := Import[int](buf, intCodec{})
+			require.NoError(t, err)
+			want := map[string]int{}
+			for _, k := range tc.want {
+				want[k] = *r.Get([]byte(k))
+			}
+			require.Equal(t, want, collect(t, r2))
+		})
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Export[int](buf, buildTree("a", "b"), intCodec{}))
+	data := buf.Bytes()
+
+	_, err := Import[int](bytes.NewReader(data[:len(data)-1]), intCodec{})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// Swap the pairs.
+	half := len(data) / 2
+	swapped := append(append([]byte{}, data[half:]...), data[:half]...)
+	_, err = Import[int](bytes.NewReader(swapped), intCodec{})
+	require.ErrorIs(t, err, ErrUnsorted)
+}
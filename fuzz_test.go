@@ -0,0 +1,247 @@
+package iradix
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	opInsert = iota
+	opDelete
+	opClone
+	opCommit
+	opSeek
+	opNext
+	opBack
+	opSeekPrefix
+	opCount
+)
+
+// FuzzTree interprets the input as a sequence of operations executed both on the tree and on the reference
+// model keeping keys in a map. Results of all the operations must be the same, and trees committed earlier
+// must not be affected by the later operations.
+//
+// Single execution is relatively expensive, so fuzzing is much faster with -fuzzminimizetime=1x.
+func FuzzTree(f *testing.F) {
+	f.Add([]byte{opInsert, 2, 2, 4, opCommit, opSeek, 0, opNext, opNext, opBack, 1, opNext})
+	f.Add([]byte{
+		opInsert, 1, 2, opInsert, 2, 2, 4, opInsert, 3, 2, 4, 6, opClone, opDelete, 2, 2, 4,
+		opCommit, opSeek, 1, 4, opNext, opNext, opBack, 2, opNext, opNext, opNext, opBack, 3, opNext,
+	})
+	f.Add([]byte{
+		opInsert, 1, 1, opInsert, 1, 3, opInsert, 1, 5, opInsert, 1, 7, opInsert, 1, 9, opInsert, 1, 11,
+		opCommit, opSeek, 0, opNext, opDelete, 1, 3, opCommit, opNext, opNext, opBack, 2, opNext,
+	})
+
+	// Iterator moved back past the beginning after seeking beyond the last subtree.
+	f.Add([]byte{opInsert, 2, 4, 1, opInsert, 1, 'c', opCommit, opSeek, 2, 4, 5, opBack, 2, opNext, opNext})
+
+	// Iterator seeked to the prefix, alone and followed by the lower bound.
+	f.Add([]byte{
+		opInsert, 1, 2, opInsert, 2, 2, 4, opInsert, 3, 2, 4, 6, opInsert, 2, 4, 2, opCommit,
+		opSeekPrefix, 1, 2, 0, opNext, opNext, opBack, 1, opNext, opNext, opNext,
+		opSeekPrefix, 1, 2, 1, 2, 2, 4, opNext, opBack, 3, opNext, opNext,
+	})
+
+	f.Fuzz(runFuzzTree)
+}
+
+func runFuzzTree(t *testing.T, data []byte) {
+	input := fuzzInput{data: data}
+	var value int
+
+	txn := newFuzzTxn(New[int](), map[string]int{}, 0)
+	var forks []fuzzTxn
+	committed := []fuzzTree{{root: txn.txn.Root(), model: map[string]int{}}}
+	iter := newFuzzIterator(committed[0])
+
+	for !input.empty() {
+		switch input.next() % opCount {
+		case opInsert:
+			k := input.key()
+			value++
+			v := value
+			old := txn.txn.Insert(k, &v)
+			requireValue(t, txn.model, k, old)
+			txn.model[string(k)] = v
+		case opDelete:
+			k := input.key()
+			old := txn.txn.Delete(k)
+			requireValue(t, txn.model, k, old)
+			delete(txn.model, string(k))
+		case opClone:
+			forks = append(forks, txn)
+			txn = fuzzTxn{txn: txn.txn.Clone(), model: maps.Clone(txn.model)}
+		case opCommit:
+			tree := fuzzTree{root: txn.txn.Commit(), model: txn.model}
+			requireTree(t, tree)
+			committed = append(committed, tree)
+			txn = newFuzzTxn(tree.root, maps.Clone(tree.model), len(committed))
+		case opSeek:
+			k := input.key()
+			iter = newFuzzIterator(committed[len(committed)-1])
+			iter.seek(k)
+		case opNext:
+			iter.requireNext(t)
+		case opBack:
+			iter.back(uint64(input.next() % 4))
+		case opSeekPrefix:
+			prefix := input.key()
+			iter = newFuzzIterator(committed[len(committed)-1])
+			iter.seekPrefix(prefix)
+			if input.next()%2 == 1 {
+				iter.seek(input.key())
+			}
+		}
+	}
+
+	for _, fork := range forks {
+		requireTree(t, fuzzTree{root: fork.txn.Commit(), model: fork.model})
+	}
+	for _, tree := range committed {
+		requireTree(t, tree)
+	}
+}
+
+type fuzzInput struct {
+	data []byte
+}
+
+func (in *fuzzInput) empty() bool {
+	return len(in.data) == 0
+}
+
+func (in *fuzzInput) next() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+// key reads the length of the key followed by its bytes. Even bytes are mapped to the small alphabet,
+// so keys share prefixes, odd ones are taken as they are, so nodes have many children.
+func (in *fuzzInput) key() []byte {
+	const alphabet = "\x00ab\xff"
+
+	k := make([]byte, in.next()%6)
+	for i := range k {
+		b := in.next()
+		if b%2 == 0 {
+			b = alphabet[b/2%byte(len(alphabet))]
+		}
+		k[i] = b
+	}
+	return k
+}
+
+type fuzzTxn struct {
+	txn   *Txn[int]
+	model map[string]int
+}
+
+func newFuzzTxn(root *Node[int], model map[string]int, commits int) fuzzTxn {
+	txn := NewTxn(root)
+	if commits%2 == 1 {
+		txn = NewArenaTxn(root)
+	}
+	return fuzzTxn{txn: txn, model: model}
+}
+
+type fuzzTree struct {
+	root  *Node[int]
+	model map[string]int
+}
+
+// fuzzIterator executes iterator operations on the tree and on the sorted keys of the model.
+type fuzzIterator struct {
+	iter  *Iterator[int]
+	model map[string]int
+	keys  []string
+	pos   int
+
+	// prefix is the one passed to SeekPrefix, lower bound is relative to it.
+	prefix []byte
+
+	// exhausted is set once Next reports the end, after that Back has no effect.
+	exhausted bool
+}
+
+func newFuzzIterator(tree fuzzTree) *fuzzIterator {
+	return &fuzzIterator{
+		iter:  tree.root.Iterator(),
+		model: tree.model,
+		keys:  slices.Sorted(maps.Keys(tree.model)),
+	}
+}
+
+// seekPrefix seeks the iterator to the prefix, so the keys of the model are limited to the ones having it.
+func (it *fuzzIterator) seekPrefix(prefix []byte) {
+	it.iter.SeekPrefix(prefix)
+	it.prefix = prefix
+	it.keys = slices.DeleteFunc(it.keys, func(k string) bool { return !strings.HasPrefix(k, string(prefix)) })
+	it.pos = 0
+	it.exhausted = false
+}
+
+func (it *fuzzIterator) seek(k []byte) {
+	it.iter.SeekLowerBound(k)
+	it.pos, _ = slices.BinarySearch(it.keys, string(it.prefix)+string(k))
+	it.exhausted = false
+}
+
+func (it *fuzzIterator) requireNext(t *testing.T) {
+	v := it.iter.Next()
+	if it.pos == len(it.keys) {
+		require.Nil(t, v)
+		it.exhausted = true
+		return
+	}
+	require.NotNil(t, v, "expected key %q", it.keys[it.pos])
+	require.Equal(t, it.model[it.keys[it.pos]], *v, "expected key %q", it.keys[it.pos])
+	it.pos++
+}
+
+func (it *fuzzIterator) back(count uint64) {
+	it.iter.Back(count)
+	if !it.exhausted {
+		it.pos = max(0, it.pos-int(count))
+	}
+}
+
+func requireValue(t *testing.T, model map[string]int, k []byte, v *int) {
+	expected, exists := model[string(k)]
+	if !exists {
+		require.Nil(t, v, "key %q", k)
+		return
+	}
+	require.NotNil(t, v, "key %q", k)
+	require.Equal(t, expected, *v, "key %q", k)
+}
+
+func requireTree(t *testing.T, tree fuzzTree) {
+	require.NoError(t, tree.root.Validate())
+
+	var keys []string
+	values := map[string]int{}
+	require.NoError(t, tree.root.walk(nil, nil, func(k []byte, v *int) error {
+		keys = append(keys, string(k))
+		values[string(k)] = *v
+		return nil
+	}))
+	require.Equal(t, slices.Sorted(maps.Keys(tree.model)), keys)
+	require.Equal(t, tree.model, values)
+
+	clear(values)
+	for k := range tree.model {
+		if v := tree.root.Get([]byte(k)); v != nil {
+			values[k] = *v
+		}
+	}
+	require.Equal(t, tree.model, values)
+}
@@ -0,0 +1,61 @@
+package iradix
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Hash is the Merkle hash of the subtree.
+type Hash [sha256.Size]byte
+
+// ValueHasher appends the bytes representing the value in the hash to buf.
+// Method Encode of the ValueCodec might be used.
+type ValueHasher[T any] func(buf []byte, v *T) []byte
+
+// Hash returns the Merkle hash of the subtree, computed over the prefixes, hashes of the values and
+// hashes of the children. Hashes are computed lazily and cached in the nodes, so the same hasher must
+// be used for all the trees sharing nodes. Trees storing the same keys and values have equal hashes.
+func (n *Node[T]) Hash(hasher ValueHasher[T]) Hash {
+	if h := n.hash.Load(); h != nil {
+		return *h
+	}
+
+	es := n.children()
+	childHashes := make([]Hash, 0, es.len())
+	labels := make([]byte, 0, es.len())
+	for label, child := range es.all() {
+		labels = append(labels, label)
+		childHashes = append(childHashes, child.Hash(hasher))
+	}
+
+	h := hashNode(n.prefix, n.valueHash(hasher), labels, childHashes)
+	n.hash.Store(&h)
+	return h
+}
+
+func (n *Node[T]) valueHash(hasher ValueHasher[T]) *Hash {
+	if !n.hasValue() {
+		return nil
+	}
+	h := Hash(sha256.Sum256(hasher(nil, &n.value)))
+	return &h
+}
+
+// hashNode computes the hash of the node. It is shared by the tree and proof verification.
+func hashNode(prefix []byte, valueHash *Hash, labels []byte, childHashes []Hash) Hash {
+	b := make([]byte, 0, 4+len(prefix)+1+len(valueHash)+4+len(labels)*(1+sha256.Size))
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(prefix)))
+	b = append(b, prefix...)
+	if valueHash == nil {
+		b = append(b, 0)
+	} else {
+		b = append(b, 1)
+		b = append(b, valueHash[:]...)
+	}
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(labels)))
+	for i, label := range labels {
+		b = append(b, label)
+		b = append(b, childHashes[i][:]...)
+	}
+	return sha256.Sum256(b)
+}
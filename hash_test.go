@@ -0,0 +1,60 @@
+package iradix
+
+import (
+	mathrand "math/rand"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHash(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	keys := make([]string, 0, 300)
+	for range cap(keys) {
+		keys = append(keys, randString(rand))
+	}
+
+	// Trees built in different order, with removed keys, are equal.
+	txn1 := NewTxn(New[int]())
+	for _, k := range keys {
+		txn1.Insert([]byte(k), lo.ToPtr(len(k)))
+	}
+	txn2 := NewTxn(New[int]())
+	for i := len(keys) - 1; i >= 0; i-- {
+		txn2.Insert([]byte(keys[i]), lo.ToPtr(len(keys[i])))
+		txn2.Insert([]byte(keys[i]+"/removed"), lo.ToPtr(0))
+	}
+	for _, k := range keys {
+		txn2.Delete([]byte(k + "/removed"))
+	}
+	r1 := txn1.Commit()
+	r2 := txn2.Commit()
+	require.Equal(t, r1.Hash(intCodec{}.Encode), r2.Hash(intCodec{}.Encode))
+
+	// Hash of the modified tree differs.
+	txn := NewTxn(r2)
+	txn.Insert([]byte(keys[0]), lo.ToPtr(-1))
+	r3 := txn.Commit()
+	require.NotEqual(t, r1.Hash(intCodec{}.Encode), r3.Hash(intCodec{}.Encode))
+
+	// Cached hashes are invalidated when transaction continues modifying its nodes.
+	txn.Insert([]byte(keys[0]), lo.ToPtr(len(keys[0])))
+	require.Equal(t, r1.Hash(intCodec{}.Encode), txn.Root().Hash(intCodec{}.Encode))
+
+	// Hash is stable across serialization.
+	m, err := OpenMappedTree[int](tempSnapshotFile[int](t, r1, intCodec{}), intCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+	require.Equal(t, r1.Hash(intCodec{}.Encode), m.Root().Hash(intCodec{}.Encode))
+}
+
+func TestHashEmptyTree(t *testing.T) {
+	r := buildTree("a")
+	txn := NewTxn(r)
+	txn.Delete([]byte("a"))
+	require.Equal(t, New[int]().Hash(intCodec{}.Encode), txn.Commit().Hash(intCodec{}.Encode))
+	require.NotEqual(t, New[int]().Hash(intCodec{}.Encode), r.Hash(intCodec{}.Encode))
+}
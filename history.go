@@ -0,0 +1,162 @@
+package iradix
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRevisionOrder is returned if version added to the history is not newer than the latest one.
+var ErrRevisionOrder = errors.New("revision is not greater than the latest one")
+
+// HistoryConfig defines which versions are retained by the history.
+type HistoryConfig struct {
+	// MaxVersions is the maximum number of retained versions. Zero means no limit.
+	MaxVersions int
+
+	// MaxAge is the maximum age of retained versions. Zero means no limit.
+	MaxAge time.Duration
+}
+
+type version[T any] struct {
+	root      *Node[T]
+	committed time.Time
+}
+
+// History retains committed roots indexed by their revisions, allowing to read the tree
+// as it was at any retained revision. Versions share unchanged nodes, so retaining them is cheap.
+// The latest version is always retained. History is safe for concurrent use.
+type History[T any] struct {
+	config HistoryConfig
+	now    func() time.Time
+
+	mu       sync.RWMutex
+	versions []version[T]
+}
+
+// NewHistory creates new history.
+func NewHistory[T any](config HistoryConfig) *History[T] {
+	return &History[T]{
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Add adds the committed root to the history and expires old versions.
+// Adding the latest root again does nothing.
+func (h *History[T]) Add(root *Node[T]) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.versions) > 0 {
+		latest := h.versions[len(h.versions)-1].root
+		if latest == root {
+			return nil
+		}
+		if root.revision <= latest.revision {
+			return ErrRevisionOrder
+		}
+	}
+
+	h.versions = append(h.versions, version[T]{root: root, committed: h.now()})
+	h.expire()
+	return nil
+}
+
+// reset removes all the versions and adds the root.
+func (h *History[T]) reset(root *Node[T]) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.versions = []version[T]{{root: root, committed: h.now()}}
+}
+
+// AsOf returns the root of the tree as it was at the revision. Nil is returned if the revision
+// is no longer retained.
+func (h *History[T]) AsOf(revision uint64) *Node[T] {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	// Index of the first version newer than the requested revision.
+	idx := sort.Search(len(h.versions), func(i int) bool {
+		return h.versions[i].root.revision > revision
+	})
+	if idx == 0 {
+		return nil
+	}
+
+	v := h.versions[idx-1]
+	if idx < len(h.versions) && h.expired(h.versions[idx].committed) {
+		// Version has been replaced by the next one long enough ago.
+		return nil
+	}
+	return v.root
+}
+
+// after returns the retained versions newer than the revision.
+func (h *History[T]) after(revision uint64) []*Node[T] {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var roots []*Node[T]
+	for _, v := range h.versions {
+		if v.root.revision > revision {
+			roots = append(roots, v.root)
+		}
+	}
+	return roots
+}
+
+// Latest returns the latest version.
+func (h *History[T]) Latest() *Node[T] {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(h.versions) == 0 {
+		return nil
+	}
+	return h.versions[len(h.versions)-1].root
+}
+
+// Revisions returns the revisions of retained versions in increasing order.
+func (h *History[T]) Revisions() []uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	revisions := make([]uint64, 0, len(h.versions))
+	for _, v := range h.versions {
+		revisions = append(revisions, v.root.revision)
+	}
+	return revisions
+}
+
+// Expire removes the versions not retained by the configuration anymore.
+func (h *History[T]) Expire() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.expire()
+}
+
+func (h *History[T]) expire() {
+	var toRemove int
+	if h.config.MaxVersions > 0 && len(h.versions) > h.config.MaxVersions {
+		toRemove = len(h.versions) - h.config.MaxVersions
+	}
+	// Version is kept as long as the next one is not older than MaxAge, because until then it
+	// represents the state of the tree in the retained time window.
+	for toRemove < len(h.versions)-1 && h.expired(h.versions[toRemove+1].committed) {
+		toRemove++
+	}
+	if toRemove == 0 {
+		return
+	}
+
+	// Versions are copied, so the removed roots are released.
+	h.versions = append(make([]version[T], 0, len(h.versions)-toRemove), h.versions[toRemove:]...)
+}
+
+func (h *History[T]) expired(replaced time.Time) bool {
+	return h.config.MaxAge > 0 && h.now().Sub(replaced) > h.config.MaxAge
+}
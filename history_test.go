@@ -0,0 +1,92 @@
+package iradix
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryMaxVersions(t *testing.T) {
+	s := NewStore(New[int]())
+	h := NewHistory[int](HistoryConfig{MaxVersions: 3})
+	require.NoError(t, s.TrackHistory(h))
+
+	for i := range 5 {
+		require.NoError(t, s.Update(func(txn *Txn[int]) error {
+			txn.Insert([]byte(fmt.Sprintf("key/%d", i)), lo.ToPtr(i))
+			return nil
+		}))
+	}
+	// Transaction without changes doesn't create new version.
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		return nil
+	}))
+
+	require.Equal(t, []uint64{3, 4, 5}, h.Revisions())
+	require.Same(t, s.Root(), h.Latest())
+	require.Nil(t, h.AsOf(2))
+	for rev := uint64(3); rev <= 5; rev++ {
+		root := h.AsOf(rev)
+		require.NotNil(t, root)
+		require.Len(t, iterateAll(root.Iterator().Next), int(rev))
+	}
+	require.Same(t, h.Latest(), h.AsOf(100))
+
+	require.ErrorIs(t, h.Add(h.AsOf(3)), ErrRevisionOrder)
+}
+
+func TestHistoryReplacedRoot(t *testing.T) {
+	s := NewStore(New[int]())
+	h := NewHistory[int](HistoryConfig{})
+	require.NoError(t, s.TrackHistory(h))
+	insertInStore(t, s, "a")
+	insertInStore(t, s, "b")
+	insertInStore(t, s, "c")
+
+	// Root received from the replication leader has lower revision than the local one.
+	txn := NewTxn(New[int]())
+	txn.Insert([]byte("x"), lo.ToPtr(1))
+	root := txn.Commit()
+	require.NoError(t, s.replaceRoot(root))
+
+	require.Equal(t, []uint64{1}, h.Revisions())
+	require.Same(t, root, h.AsOf(1))
+
+	// History tracks next commits.
+	insertInStore(t, s, "y")
+	require.Equal(t, []uint64{1, 2}, h.Revisions())
+	require.Same(t, s.Root(), h.Latest())
+}
+
+func TestHistoryMaxAge(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := NewHistory[int](HistoryConfig{MaxAge: time.Minute})
+	h.now = func() time.Time { return now }
+
+	root := New[int]()
+	for i := range 4 {
+		txn := NewTxn(root)
+		txn.Insert([]byte(fmt.Sprintf("key/%d", i)), lo.ToPtr(i))
+		root = txn.Commit()
+		require.NoError(t, h.Add(root))
+		now = now.Add(time.Minute)
+	}
+	// Version 1 was expired when version 4 was added.
+	require.Equal(t, []uint64{2, 3, 4}, h.Revisions())
+
+	// Version 2 was replaced 2 minutes ago, so reads from that time are not possible anymore.
+	require.Nil(t, h.AsOf(2))
+	require.NotNil(t, h.AsOf(3))
+
+	h.Expire()
+	require.Equal(t, []uint64{3, 4}, h.Revisions())
+
+	// The latest version is always retained.
+	now = now.Add(time.Hour)
+	h.Expire()
+	require.Equal(t, []uint64{4}, h.Revisions())
+	require.Same(t, root, h.AsOf(4))
+}
@@ -18,18 +18,36 @@ func New[T any]() *Node[T] {
 func NewTxn[T any](root *Node[T]) *Txn[T] {
 	return &Txn[T]{
 		revision: root.revision + 1,
+		base:     root,
 		root:     root,
 	}
 }
 
+// NewArenaTxn works like NewTxn but the transaction allocates new nodes and edge lists in chunks,
+// reducing the number of allocations made by large transactions. GC scanning is not reduced, chunks
+// are scanned like separately allocated nodes. Committed tree might be shared and retained like any other
+// one, but nodes allocated from the same chunk are released together, so retaining few nodes created
+// by the transaction keeps their chunks, 256 nodes and 2048 edges each, in memory.
+func NewArenaTxn[T any](root *Node[T]) *Txn[T] {
+	t := NewTxn(root)
+	t.arena = &arena[T]{}
+	return t
+}
+
 // Txn is a transaction on the tree. This transaction is applied
 // atomically and returns a new tree when committed. A transaction
 // is not thread safe, and should only be used by a single goroutine.
 type Txn[T any] struct {
 	revision uint64
 
+	// base is the root the transaction has been created for.
+	base *Node[T]
+
 	// root is the modified root for the transaction.
 	root *Node[T]
+
+	// arena allocates nodes and edge lists if set.
+	arena *arena[T]
 }
 
 // Root returns the current root of the radix tree within this
@@ -40,14 +58,36 @@ func (t *Txn[T]) Root() *Node[T] {
 }
 
 // Get is used to lookup a specific key, returning
-// the value and if it was found.
+// the value and if it was found. Value is stored in the node modified by the transaction in place,
+// so returned pointer is valid only until the key is modified by the transaction again.
 func (t *Txn[T]) Get(k []byte) *T {
 	return t.root.Get(k)
 }
 
-// Insert is used to add or update a given key. The return provides
-// the previous value and a bool indicating if any was set.
+// Insert is used to add or update a given key, storing a copy of *v in the node, so modifying *v later
+// doesn't affect the tree. The return provides the pointer to the previous value stored in the tree,
+// not the one passed to Insert before, or nil if key did not exist.
 func (t *Txn[T]) Insert(k []byte, v *T) *T {
+	oldValue, oldNode, exists := t.insert(k, v)
+	return oldRef(oldValue, oldNode, exists)
+}
+
+// Lookup returns the value of the key and true, or zero value and false if key does not exist.
+func (t *Txn[T]) Lookup(k []byte) (T, bool) {
+	return t.root.Lookup(k)
+}
+
+// Set adds or updates the key, storing v in the node. The previous value and true are returned
+// if key existed. Unlike Insert, Set neither requires the pointer to the value nor allocates the copy
+// of the previous one.
+func (t *Txn[T]) Set(k []byte, v T) (T, bool) {
+	oldValue, _, exists := t.insert(k, &v)
+	return oldValue, exists
+}
+
+// insert stores the copy of v under the key and returns the previous value. If the previous value
+// is kept by the node not owned by the transaction, that node is returned too.
+func (t *Txn[T]) insert(k []byte, v *T) (T, *Node[T], bool) {
 	if k == nil {
 		k = []byte{}
 	}
@@ -55,14 +95,18 @@ func (t *Txn[T]) Insert(k []byte, v *T) *T {
 	n := &t.root
 	search := k
 	for {
-		nc := t.writeNode(*n)
+		orig := *n
+		nc := t.writeNode(orig)
 		*n = nc
 
 		// Handle key exhaustion.
 		if len(search) == 0 {
-			oldValue := nc.value
-			nc.value = v
-			return oldValue
+			oldValue, exists := nc.value, nc.hasValue()
+			nc.setValue(v)
+			if orig == nc {
+				orig = nil
+			}
+			return oldValue, orig, exists
 		}
 
 		// Look for the edge.
@@ -72,28 +116,24 @@ func (t *Txn[T]) Insert(k []byte, v *T) *T {
 		if child == nil {
 			nc.addEdge(edge[T]{
 				label: search[0],
-				node: &Node[T]{
-					revision: t.revision,
-					value:    v,
-					prefix:   copyPrefix(search),
-				},
-			})
-			return nil
+				node:  t.newLeaf(search, v),
+			}, t.arena)
+			var zero T
+			return zero, nil, false
 		}
 
 		// Determine longest prefix of the search key on match.
 		commonPrefix := longestPrefix(search, child.prefix)
 		if commonPrefix == len(child.prefix) {
 			search = search[commonPrefix:]
-			n = &nc.edges[idx].node
+			n = nc.edges.ref(idx)
 			continue
 		}
 
 		// Split the node.
-		splitNode := &Node[T]{
-			revision: t.revision,
-			prefix:   copyPrefix(search[:commonPrefix]),
-		}
+		splitNode := t.arena.newNode()
+		splitNode.revision = t.revision
+		splitNode.setPrefix(search[:commonPrefix])
 		nc.replaceEdge(edge[T]{
 			label: search[0],
 			node:  splitNode,
@@ -104,45 +144,54 @@ func (t *Txn[T]) Insert(k []byte, v *T) *T {
 		splitNode.addEdge(edge[T]{
 			label: modChild.prefix[commonPrefix],
 			node:  modChild,
-		})
-		modChild.prefix = modChild.prefix[commonPrefix:]
+		}, t.arena)
+		if len(modChild.prefix)-commonPrefix <= inlinePrefixSize {
+			modChild.setPrefix(modChild.prefix[commonPrefix:])
+		} else {
+			modChild.prefix = modChild.prefix[commonPrefix:]
+		}
 
 		// If the new key is a subset, add to this node.
 		search = search[commonPrefix:]
 		if len(search) == 0 {
-			splitNode.value = v
-			return nil
+			splitNode.setValue(v)
+			var zero T
+			return zero, nil, false
 		}
 
 		// Create a new edge for the node.
 		splitNode.addEdge(edge[T]{
 			label: search[0],
-			node: &Node[T]{
-				revision: t.revision,
-				value:    v,
-				prefix:   copyPrefix(search),
-			},
-		})
-		return nil
+			node:  t.newLeaf(search, v),
+		}, t.arena)
+		var zero T
+		return zero, nil, false
 	}
 }
 
-// Delete is used to delete a given key. Returns the old value if any,
-// and a bool indicating if the key was set.
+// Delete is used to delete a given key. Returns the pointer to the value stored in the tree,
+// not the one passed to Insert, or nil if key did not exist.
 func (t *Txn[T]) Delete(k []byte) *T {
 	if k == nil {
 		k = []byte{}
 	}
 
-	newRoot, oldValue := t.delete(t.root, k)
-	if newRoot != nil {
-		t.root = newRoot
+	newRoot, oldValue, oldNode := t.delete(t.root, k)
+	if newRoot == nil {
+		return nil
 	}
-	return oldValue
+	t.root = newRoot
+	return oldRef(oldValue, oldNode, true)
 }
 
 // Commit is used to finalize the transaction and return a new tree.
+// Built with iradixdebug tag, it panics if the tree violates structural invariants.
 func (t *Txn[T]) Commit() *Node[T] {
+	if debug {
+		if err := t.root.Validate(); err != nil {
+			panic(err)
+		}
+	}
 	return t.root
 }
 
@@ -153,10 +202,16 @@ func (t *Txn[T]) Commit() *Node[T] {
 // may only be mutated in a single thread.
 func (t *Txn[T]) Clone() *Txn[T] {
 	t.revision++
-	return &Txn[T]{
+	c := &Txn[T]{
 		revision: t.revision,
+		base:     t.base,
 		root:     t.root,
 	}
+	if t.arena != nil {
+		// Chunks can't be shared because both transactions would allocate the same nodes.
+		c.arena = &arena[T]{}
+	}
+	return c
 }
 
 // writeNode returns a node to be modified, if the current node has already been
@@ -165,6 +220,8 @@ func (t *Txn[T]) Clone() *Txn[T] {
 // which will set leaf mutation tracking appropriately as well.
 func (t *Txn[T]) writeNode(n *Node[T]) *Node[T] {
 	if n.revision == t.revision {
+		// Node is going to be modified so the cached hash is no longer valid.
+		n.hash.Store(nil)
 		return n
 	}
 
@@ -172,86 +229,130 @@ func (t *Txn[T]) writeNode(n *Node[T]) *Node[T] {
 	// safe to replace this leaf with another after you get your node for
 	// writing. You MUST replace it, because the channel associated with
 	// this leaf will be closed when this transaction is committed.
-	nc := &Node[T]{
-		revision: t.revision,
-		value:    n.value,
-		prefix:   n.prefix,
-	}
-	if len(n.edges) != 0 {
-		// +2 is for possible new edges, to avoid slice growing later
-		nc.edges = make([]edge[T], len(n.edges), len(n.edges)+2)
-		copy(nc.edges, n.edges)
+	nc := t.arena.newNode()
+	nc.revision = t.revision
+	nc.copyValue(n)
+	nc.edges = n.children().clone(t.arena)
+	if len(n.prefix) <= inlinePrefixSize {
+		nc.setPrefix(n.prefix)
+	} else {
+		// Long prefixes are never modified in place, so they might be shared.
+		nc.prefix = n.prefix
 	}
 
 	return nc
 }
 
+// newLeaf creates the node storing the value under the prefix.
+func (t *Txn[T]) newLeaf(prefix []byte, v *T) *Node[T] {
+	n := t.arena.newNode()
+	n.revision = t.revision
+	n.setValue(v)
+	n.setPrefix(prefix)
+	return n
+}
+
 // mergeChild is called to collapse the given node with its child. This is only
 // called when the given node is not a leaf and has a single edge.
 func (t *Txn[T]) mergeChild(n *Node[T]) {
 	// Mark the child node as being mutated since we are about to abandon
 	// it. We don't need to mark the leaf since we are retaining it if it
 	// is there.
-	e := n.edges[0]
-	child := e.node
+	child := n.edges.at(n.edges.first()).node
 
 	// Merge the nodes.
-	n.prefix = concatPrefixes(n.prefix, child.prefix)
-	n.value = child.value
-	if len(child.edges) != 0 {
-		n.edges = make([]edge[T], len(child.edges))
-		copy(n.edges, child.edges)
+	if size := len(n.prefix) + len(child.prefix); size <= inlinePrefixSize {
+		var prefix [inlinePrefixSize]byte
+		copy(prefix[copy(prefix[:], n.prefix):], child.prefix)
+		n.setPrefix(prefix[:size])
 	} else {
-		n.edges = nil
+		n.prefix = concatPrefixes(n.prefix, child.prefix)
 	}
+	n.copyValue(child)
+	n.edges = child.children().clone(t.arena)
 }
 
-func (t *Txn[T]) delete(n *Node[T], search []byte) (*Node[T], *T) {
+// delete removes the key from the subtree and returns the modified node together with the removed value.
+// If the removed value is kept by the node not owned by the transaction, that node is returned too.
+// Nil node is returned if key does not exist.
+func (t *Txn[T]) delete(n *Node[T], search []byte) (*Node[T], T, *Node[T]) {
+	var zero T
+
 	// Check for key exhaustion.
 	if len(search) == 0 {
-		if n.value == nil {
-			return nil, nil
+		if !n.hasValue() {
+			return nil, zero, nil
 		}
 
 		// Remove the leaf node.
 		nc := t.writeNode(n)
 		oldValue := nc.value
-		nc.value = nil
+		nc.clearValue()
+		oldNode := n
+		if oldNode == nc {
+			oldNode = nil
+		}
 
 		// Check if this node should be merged.
-		if n != t.root && len(nc.edges) == 1 {
+		if n != t.root && nc.edges.len() == 1 {
 			t.mergeChild(nc)
 		}
-		return nc, oldValue
+		return nc, oldValue, oldNode
 	}
 
 	// Look for an edge.
 	label := search[0]
 	idx, child := n.getEdge(label)
 	if child == nil || !bytes.HasPrefix(search, child.prefix) {
-		return nil, nil
+		return nil, zero, nil
 	}
 
 	// Consume the search prefix
 	search = search[len(child.prefix):]
-	newChild, oldValue := t.delete(child, search)
+	newChild, oldValue, oldNode := t.delete(child, search)
 	if newChild == nil {
-		return nil, nil
+		return nil, zero, nil
 	}
 
 	// Copy this node.
 	nc := t.writeNode(n)
 
 	// Delete the edge if the node has no edges.
-	if newChild.value == nil && len(newChild.edges) == 0 {
-		nc.delEdge(label)
-		if n != t.root && len(nc.edges) == 1 && nc.value == nil {
+	if !newChild.hasValue() && newChild.edges.len() == 0 {
+		nc.delEdge(label, t.arena)
+		if n != t.root && nc.edges.len() == 1 && !nc.hasValue() {
 			t.mergeChild(nc)
 		}
 	} else {
-		nc.edges[idx].node = newChild
+		*nc.edges.ref(idx) = newChild
+	}
+	return nc, oldValue, oldNode
+}
+
+// oldRef returns the pointer to the value replaced or deleted by the transaction. Nodes not owned
+// by the transaction are never modified, so the pointer to the value they keep is returned.
+// Otherwise, the value has been overwritten in place, so the copy is allocated.
+func oldRef[T any](oldValue T, oldNode *Node[T], exists bool) *T {
+	switch {
+	case !exists:
+		return nil
+	case oldNode != nil:
+		return &oldNode.value
+	default:
+		return valueCopy(oldValue)
+	}
+}
+
+func valueCopy[T any](v T) *T {
+	return &v
+}
+
+func deref[T any](v *T) (T, bool) {
+	if v == nil {
+		var zero T
+		return zero, false
 	}
-	return nc, oldValue
+	return *v, true
 }
 
 func longestPrefix(k1, k2 []byte) int {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	mathrand "math/rand"
 	"reflect"
+	"slices"
 	"sort"
 	"strings"
 	"testing"
@@ -19,19 +20,29 @@ import (
 func CopyTree[T any](t *Node[T]) *Node[T] {
 	nn := &Node[T]{
 		revision: t.revision,
-		value:    t.value,
 	}
-	if t.prefix != nil {
-		nn.prefix = make([]byte, len(t.prefix))
-		copy(nn.prefix, t.prefix)
+	nn.copyValue(t)
+	nn.setPrefix(t.prefix)
+	for label, child := range t.edges.all() {
+		nn.addEdge(edge[T]{label: label, node: CopyTree(child)}, nil)
 	}
-	if len(t.edges) != 0 {
-		nn.edges = make([]edge[T], len(t.edges))
-		for idx, edge := range t.edges {
-			nn.edges[idx].label = edge.label
-			nn.edges[idx].node = CopyTree(edge.node)
+	return nn
+}
+
+// CopyTreeValues works like CopyTree but all the values get the same identifier, so copies of the trees
+// storing equal values are equal even if values have been set separately.
+func CopyTreeValues[T any](t *Node[T]) *Node[T] {
+	nn := CopyTree(t)
+	var reset func(n *Node[T])
+	reset = func(n *Node[T]) {
+		if n.hasValue() {
+			n.valueID = 1
+		}
+		for _, child := range n.edges.all() {
+			reset(child)
 		}
 	}
+	reset(nn)
 	return nn
 }
 
@@ -1508,6 +1519,25 @@ func TestIterateBack(t *testing.T) {
 				"4",
 			},
 		},
+		{
+			// Lower bound is placed after the "found" subtree, skipped because its prefix is lower.
+			keys:  mixedLenKeys,
+			lower: "founz",
+			steps: []int{-2},
+			want: []string{
+				"foo",
+				"found",
+				"zap",
+				"zip",
+			},
+		},
+		{
+			// Moving back past the beginning starts iteration from the first key.
+			keys:  mixedLenKeys,
+			lower: "founz",
+			steps: []int{-10},
+			want:  mixedLenKeys,
+		},
 	}
 
 	for idx, test := range cases {
@@ -1961,6 +1991,174 @@ func TestClone(t *testing.T) {
 	}
 }
 
+func TestSetLookup(t *testing.T) {
+	txn := NewTxn(New[int]())
+	for i := range 100 {
+		_, exists := txn.Set([]byte(fmt.Sprintf("%03d", i)), i)
+		require.False(t, exists)
+	}
+	v, exists := txn.Set([]byte("000"), 7)
+	require.True(t, exists)
+	require.Equal(t, 0, v)
+
+	t2 := txn.Clone()
+	txn.Set([]byte("001"), 0)
+	t2.Set([]byte("001"), 8)
+	r1, r2 := txn.Commit(), t2.Commit()
+
+	for i := 2; i < 100; i++ {
+		v, exists := r1.Lookup([]byte(fmt.Sprintf("%03d", i)))
+		require.True(t, exists)
+		require.Equal(t, i, v)
+	}
+	v, exists = r1.Lookup([]byte("000"))
+	require.True(t, exists)
+	require.Equal(t, 7, v)
+	v, exists = r1.Lookup([]byte("001"))
+	require.True(t, exists)
+	require.Equal(t, 0, v)
+	v, exists = r2.Lookup([]byte("001"))
+	require.True(t, exists)
+	require.Equal(t, 8, v)
+	_, exists = r1.Lookup([]byte("00"))
+	require.False(t, exists)
+
+	it := r1.Iterator()
+	it.SeekPrefix([]byte("00"))
+	var values []int
+	for v, ok := it.NextValue(); ok; v, ok = it.NextValue() {
+		values = append(values, v)
+	}
+	require.Equal(t, []int{7, 0, 2, 3, 4, 5, 6, 7, 8, 9}, values)
+}
+
+func TestInlineValues(t *testing.T) {
+	txn := NewTxn(New[int]())
+	txn.Set([]byte("a"), 1)
+	txn.Set([]byte("b"), 2)
+	r1 := txn.Commit()
+	a1 := r1.Get([]byte("a"))
+
+	txn = NewTxn(r1)
+	old := txn.Insert([]byte("a"), lo.ToPtr(3))
+	require.Equal(t, 1, *old)
+
+	// Node owned by the transaction is modified in place, so the old value must be copied.
+	old = txn.Insert([]byte("a"), lo.ToPtr(4))
+	require.Equal(t, 3, *old)
+	old = txn.Delete([]byte("a"))
+	require.Equal(t, 4, *old)
+	txn.Set([]byte("a"), 5)
+	require.Equal(t, 4, *old)
+
+	// Updating the key created by the transaction does not allocate.
+	txn.Set([]byte("d"), 0)
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		txn.Set([]byte("d"), 6)
+	}))
+	r2 := txn.Commit()
+
+	// Values of the committed tree are not affected.
+	require.Equal(t, 1, *a1)
+	require.Same(t, a1, r1.Get([]byte("a")))
+	require.Equal(t, 5, *r2.Get([]byte("a")))
+
+	// Value copied with the node keeps its identity, so only the values set again are reported as changed,
+	// even if they are equal to the previous ones.
+	txn = NewTxn(r2)
+	txn.Set([]byte("b"), 2)
+	txn.Set([]byte("c"), 7)
+	r3 := txn.Commit()
+	require.Empty(t, Diff(r1, r1))
+	changes := Diff(r2, r3)
+	require.Len(t, changes, 2)
+	require.Equal(t, "b", string(changes[0].Key))
+	require.Equal(t, 2, *changes[0].Old)
+	require.Equal(t, 2, *changes[0].New)
+	require.Equal(t, "c", string(changes[1].Key))
+	require.Nil(t, changes[1].Old)
+}
+
+func BenchmarkInsert(b *testing.B) {
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key/%04d", i))
+	}
+
+	b.Run("Insert", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			txn := NewTxn(New[int]())
+			for i, k := range keys {
+				txn.Insert(k, lo.ToPtr(i))
+			}
+		}
+	})
+	b.Run("Set", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			txn := NewTxn(New[int]())
+			for i, k := range keys {
+				txn.Set(k, i)
+			}
+		}
+	})
+}
+
+func BenchmarkDelete(b *testing.B) {
+	txn := NewTxn(New[int]())
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key/%04d", i))
+		txn.Insert(keys[i], lo.ToPtr(i))
+	}
+	r := txn.Commit()
+
+	b.ReportAllocs()
+	for range b.N {
+		txn := NewTxn(r)
+		for _, k := range keys {
+			txn.Delete(k)
+		}
+	}
+}
+
+// BenchmarkLargeValues compares large values stored inline with pointers to them used as values. Inline
+// values are copied together with the nodes on the path to the updated key, and take space in the nodes
+// without values too.
+func BenchmarkLargeValues(b *testing.B) {
+	type large [256]byte
+
+	keys := make([][]byte, 1000)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("key/%04d", i))
+	}
+
+	b.Run("Inline", func(b *testing.B) {
+		benchmarkUpdates(b, keys, func() large { return large{} })
+	})
+	b.Run("Pointer", func(b *testing.B) {
+		benchmarkUpdates(b, keys, func() *large { return &large{} })
+	})
+}
+
+func benchmarkUpdates[T any](b *testing.B, keys [][]byte, value func() T) {
+	txn := NewTxn(New[T]())
+	for _, k := range keys {
+		txn.Set(k, value())
+	}
+	r := txn.Commit()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		txn := NewTxn(r)
+		txn.Set(keys[i%len(keys)], value())
+		txn.Commit()
+	}
+	b.ReportMetric(float64(r.Stats().Bytes)/float64(len(keys)), "tree-B/key")
+}
+
 func randomString(t *testing.T) string {
 	var gen [16]byte
 	_, err := rand.Read(gen[:])
@@ -1969,3 +2167,111 @@ func randomString(t *testing.T) string {
 	}
 	return hex.EncodeToString(gen[:])
 }
+
+func TestPrefixInlineBoundary(t *testing.T) {
+	a16 := strings.Repeat("a", 16)
+	a17 := strings.Repeat("a", 17)
+
+	tests := []struct {
+		name    string
+		insert  []string
+		delete  []string
+		path    string
+		prefix  string
+		spilled bool
+	}{
+		{
+			name:   "16 bytes",
+			insert: []string{a16},
+			path:   a16,
+			prefix: a16,
+		},
+		{
+			name:    "17 bytes",
+			insert:  []string{a17},
+			path:    a17,
+			prefix:  a17,
+			spilled: true,
+		},
+		{
+			name:   "split of spilled prefix into inline parent",
+			insert: []string{a17 + "b", a16 + "c"},
+			path:   a16,
+			prefix: a16,
+		},
+		{
+			name:   "split of spilled prefix into inline child",
+			insert: []string{"b" + a16, "bc"},
+			path:   "b" + a16,
+			prefix: a16,
+		},
+		{
+			name:    "split keeping spilled child",
+			insert:  []string{"bb" + a17, "bc"},
+			path:    "bb" + a17,
+			prefix:  "b" + a17,
+			spilled: true,
+		},
+		{
+			name:   "merge into inline prefix",
+			insert: []string{"b" + a16[1:] + "x", "b" + a16[1:] + "y", "bz"},
+			delete: []string{"bz"},
+			path:   "b" + a16[1:],
+			prefix: "b" + a16[1:],
+		},
+		{
+			name:    "merge into spilled prefix",
+			insert:  []string{"b" + a16 + "x", "b" + a16 + "y", "bz"},
+			delete:  []string{"bz"},
+			path:    "b" + a16,
+			prefix:  "b" + a16,
+			spilled: true,
+		},
+		{
+			name:   "merge of inline prefixes",
+			insert: []string{"b" + a16[2:] + "x", "by"},
+			delete: []string{"by"},
+			path:   "b" + a16[2:] + "x",
+			prefix: "b" + a16[2:] + "x",
+		},
+		{
+			name:    "merge exceeding inline size",
+			insert:  []string{"b" + a16 + "x", "by"},
+			delete:  []string{"by"},
+			path:    "b" + a16 + "x",
+			prefix:  "b" + a16 + "x",
+			spilled: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			txn := NewTxn(New[int]())
+			for i, k := range test.insert {
+				txn.Insert([]byte(k), lo.ToPtr(i))
+			}
+			r1 := txn.Commit()
+			before := collect(t, r1)
+
+			txn = NewTxn(r1)
+			for _, k := range test.delete {
+				txn.Delete([]byte(k))
+			}
+			r2 := txn.Commit()
+			require.NoError(t, r2.Validate())
+
+			n := r2.nodeAt([]byte(test.path))
+			require.NotNil(t, n)
+			require.Equal(t, test.prefix, string(n.prefix))
+			require.Equal(t, test.spilled, &n.prefix[0] != &n.inlinePrefix[0])
+
+			// Tree modified by the transaction is not affected.
+			require.Equal(t, before, collect(t, r1))
+			for k, v := range before {
+				if !slices.Contains(test.delete, k) {
+					require.Equal(t, v, *r2.Get([]byte(k)))
+				}
+			}
+		})
+	}
+}
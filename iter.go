@@ -5,7 +5,7 @@ import (
 )
 
 type item[T any] struct {
-	edges          edges[T]
+	edges          *edges[T]
 	index1, index2 int
 }
 
@@ -70,7 +70,7 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 			return
 		}
 
-		if prefixCmp > 0 && n.value != nil {
+		if prefixCmp > 0 && n.hasValue() {
 			return
 		}
 
@@ -81,7 +81,6 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 		}
 
 		if prefixCmp < 0 {
-			i.node = nil
 			return
 		}
 
@@ -95,7 +94,7 @@ func (i *Iterator[T]) SeekLowerBound(key []byte) {
 			return
 		}
 
-		i.stack = append(i.stack, item[T]{edges: n.edges, index1: idx, index2: idx})
+		i.stack = append(i.stack, item[T]{edges: n.children(), index1: idx, index2: idx})
 	}
 }
 
@@ -106,18 +105,23 @@ func (i *Iterator[T]) Next() *T {
 	}
 
 	for len(i.stack) > 0 {
-		if n := i.forward(); n != nil && n.value != nil {
-			return n.value
+		if n := i.forward(); n != nil && n.hasValue() {
+			return &n.value
 		}
 	}
 	return nil
 }
 
+// NextValue works like Next but returns the value and true, or zero value and false if there are no more values.
+func (i *Iterator[T]) NextValue() (T, bool) {
+	return deref(i.Next())
+}
+
 // Back moves iterator back.
 func (i *Iterator[T]) Back(count uint64) {
 	for len(i.stack) > 0 && count > 0 {
 		n := i.backward()
-		if n != nil && n.value != nil {
+		if n != nil && n.hasValue() {
 			count--
 		}
 	}
@@ -125,21 +129,21 @@ func (i *Iterator[T]) Back(count uint64) {
 
 func (i *Iterator[T]) peek() *Node[T] {
 	itm := i.stack[len(i.stack)-1]
-	return itm.edges[itm.index1].node
+	return itm.edges.at(itm.index1).node
 }
 
 func (i *Iterator[T]) pop() *Node[T] {
-	itm := i.stack[len(i.stack)-1]
-	n := itm.edges[itm.index1].node
+	itm := &i.stack[len(i.stack)-1]
+	n := itm.edges.at(itm.index1).node
 
-	i.stack[len(i.stack)-1].index1++
-	i.stack[len(i.stack)-1].index2++
+	itm.index1 = itm.edges.next(itm.index1)
+	itm.index2 = itm.edges.next(itm.index2)
 	return n
 }
 
 func (i *Iterator[T]) forward() *Node[T] {
 	itm := &i.stack[len(i.stack)-1]
-	if itm.index2 == len(itm.edges) {
+	if itm.index2 == itm.edges.end() {
 		i.stack = i.stack[:len(i.stack)-1]
 		if len(i.stack) > 0 {
 			itm := &i.stack[len(i.stack)-1]
@@ -148,12 +152,13 @@ func (i *Iterator[T]) forward() *Node[T] {
 		return nil
 	}
 
-	n := itm.edges[itm.index2].node
-	itm.index1++
-	itm.index2++
+	n := itm.edges.at(itm.index2).node
+	itm.index1 = itm.edges.next(itm.index1)
+	itm.index2 = itm.edges.next(itm.index2)
 
-	if len(n.edges) > 0 {
-		i.stack = append(i.stack, item[T]{edges: n.edges, index1: 0, index2: 0})
+	if es := n.children(); es.len() > 0 {
+		first := es.first()
+		i.stack = append(i.stack, item[T]{edges: es, index1: first, index2: first})
 	}
 
 	return n
@@ -161,7 +166,7 @@ func (i *Iterator[T]) forward() *Node[T] {
 
 func (i *Iterator[T]) backward() *Node[T] {
 	itm := &i.stack[len(i.stack)-1]
-	if itm.index1 == 0 {
+	if itm.edges.prev(itm.index1) < 0 {
 		i.stack = i.stack[:len(i.stack)-1]
 		if len(i.stack) == 0 {
 			i.stack = nil
@@ -170,31 +175,32 @@ func (i *Iterator[T]) backward() *Node[T] {
 
 		itm := &i.stack[len(i.stack)-1]
 		if itm.index1 == itm.index2 {
-			itm.index1--
-			itm.index2--
-			return itm.edges[itm.index1].node
+			itm.index1 = itm.edges.prev(itm.index1)
+			itm.index2 = itm.edges.prev(itm.index2)
+			return itm.edges.at(itm.index1).node
 		}
 		return nil
 	}
 
 	if itm.index1 == itm.index2 {
-		itm.index2--
-		n := itm.edges[itm.index2].node
-		if len(n.edges) > 0 {
-			i.stack = append(i.stack, item[T]{edges: n.edges, index1: len(n.edges), index2: len(n.edges)})
+		itm.index2 = itm.edges.prev(itm.index2)
+		n := itm.edges.at(itm.index2).node
+		if es := n.children(); es.len() > 0 {
+			i.stack = append(i.stack, item[T]{edges: es, index1: es.end(), index2: es.end()})
 			return nil
 		}
 	}
 
-	itm.index1--
-	return itm.edges[itm.index1].node
+	itm.index1 = itm.edges.prev(itm.index1)
+	return itm.edges.at(itm.index1).node
 }
 
 func (i *Iterator[T]) findMin(n *Node[T]) {
 	for {
-		i.stack = append(i.stack, item[T]{edges: n.edges, index1: 0, index2: 0})
-		n = n.edges[0].node
-		if n.value != nil {
+		es := n.children()
+		i.stack = append(i.stack, item[T]{edges: es, index1: es.first(), index2: es.first()})
+		n = es.at(es.first()).node
+		if n.hasValue() {
 			return
 		}
 		i.pop()
@@ -202,18 +208,17 @@ func (i *Iterator[T]) findMin(n *Node[T]) {
 }
 
 func (i *Iterator[T]) initStack() {
+	n := &Node[T]{
+		revision: i.node.revision,
+		prefix:   i.node.prefix[i.skip:],
+		edges:    *i.node.children(),
+	}
+	n.copyValue(i.node)
+	root := &edges[T]{}
+	root.add(edge[T]{node: n}, nil)
 	i.stack = []item[T]{
 		{
-			edges: edges[T]{
-				{
-					node: &Node[T]{
-						revision: i.node.revision,
-						value:    i.node.value,
-						prefix:   i.node.prefix[i.skip:],
-						edges:    i.node.edges,
-					},
-				},
-			},
+			edges:  root,
 			index1: 0,
 			index2: 0,
 		},
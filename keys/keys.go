@@ -0,0 +1,418 @@
+// Package keys provides order-preserving encodings of keys stored in the radix tree.
+//
+// For every codec the encoded keys compare, using bytes.Compare, in the same order as the
+// original values. All the encodings are self-delimiting, so they may be combined into tuples
+// and reversed to produce descending order. Codecs satisfy iradix.KeyCodec.
+package keys
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrInvalidKey is returned if encoded key can't be decoded.
+var ErrInvalidKey = errors.New("invalid key")
+
+// Codec converts keys of type K to and from their order-preserving binary representation.
+type Codec[K any] interface {
+	// Encode appends binary representation of k to buf and returns the extended buffer.
+	Encode(buf []byte, k K) []byte
+
+	// Decode decodes key from data. Whole data must be consumed.
+	Decode(data []byte) (K, error)
+
+	// DecodePrefix decodes key from the beginning of data and returns the remaining bytes.
+	DecodePrefix(data []byte) (K, []byte, error)
+}
+
+// Unsigned is the constraint for unsigned integer types.
+type Unsigned interface {
+	~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr
+}
+
+// Signed is the constraint for signed integer types.
+type Signed interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64
+}
+
+// Float is the constraint for floating-point types.
+type Float interface {
+	~float32 | ~float64
+}
+
+// Uint encodes unsigned integers as 8 bytes in big-endian order.
+type Uint[T Unsigned] struct{}
+
+// Encode appends k to buf.
+func (Uint[T]) Encode(buf []byte, k T) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(k))
+}
+
+// Decode decodes key from data.
+func (c Uint[T]) Decode(data []byte) (T, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (Uint[T]) DecodePrefix(data []byte) (T, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, ErrInvalidKey
+	}
+	v := binary.BigEndian.Uint64(data)
+	if uint64(T(v)) != v {
+		return 0, nil, fmt.Errorf("value %d overflows key type: %w", v, ErrInvalidKey)
+	}
+	return T(v), data[8:], nil
+}
+
+// Int encodes signed integers as 8 bytes in big-endian order with the sign bit flipped,
+// so negative numbers are ordered before positive ones.
+type Int[T Signed] struct{}
+
+// Encode appends k to buf.
+func (Int[T]) Encode(buf []byte, k T) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(int64(k))^(1<<63))
+}
+
+// Decode decodes key from data.
+func (c Int[T]) Decode(data []byte) (T, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (Int[T]) DecodePrefix(data []byte) (T, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, ErrInvalidKey
+	}
+	v := int64(binary.BigEndian.Uint64(data) ^ (1 << 63))
+	if int64(T(v)) != v {
+		return 0, nil, fmt.Errorf("value %d overflows key type: %w", v, ErrInvalidKey)
+	}
+	return T(v), data[8:], nil
+}
+
+// FloatCodec encodes floating-point numbers as 8 bytes. Bits of negative numbers are inverted
+// and the sign bit of the other ones is set, so the order of the encoded IEEE 754 representation
+// matches the numeric one. Negative zero is ordered before positive zero and NaNs are ordered
+// at the ends, depending on their sign bit.
+type FloatCodec[T Float] struct{}
+
+// Encode appends k to buf.
+func (FloatCodec[T]) Encode(buf []byte, k T) []byte {
+	bits := math.Float64bits(float64(k))
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(buf, bits)
+}
+
+// Decode decodes key from data.
+func (c FloatCodec[T]) Decode(data []byte) (T, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (FloatCodec[T]) DecodePrefix(data []byte) (T, []byte, error) {
+	if len(data) < 8 {
+		return 0, nil, ErrInvalidKey
+	}
+	bits := binary.BigEndian.Uint64(data)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return T(math.Float64frombits(bits)), data[8:], nil
+}
+
+// Bool encodes false as 0x00 and true as 0x01.
+type Bool struct{}
+
+// Encode appends k to buf.
+func (Bool) Encode(buf []byte, k bool) []byte {
+	if k {
+		return append(buf, 0x01)
+	}
+	return append(buf, 0x00)
+}
+
+// Decode decodes key from data.
+func (c Bool) Decode(data []byte) (bool, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (Bool) DecodePrefix(data []byte) (bool, []byte, error) {
+	if len(data) == 0 || data[0] > 0x01 {
+		return false, nil, ErrInvalidKey
+	}
+	return data[0] == 0x01, data[1:], nil
+}
+
+// Time encodes timestamps as seconds since Unix epoch (encoded like Int) followed by 4 bytes
+// of nanoseconds. Location is not stored, decoded timestamps are in UTC.
+type Time struct{}
+
+// Encode appends k to buf.
+func (Time) Encode(buf []byte, k time.Time) []byte {
+	buf = Int[int64]{}.Encode(buf, k.Unix())
+	return binary.BigEndian.AppendUint32(buf, uint32(k.Nanosecond()))
+}
+
+// Decode decodes key from data.
+func (c Time) Decode(data []byte) (time.Time, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (Time) DecodePrefix(data []byte) (time.Time, []byte, error) {
+	sec, data, err := Int[int64]{}.DecodePrefix(data)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	if len(data) < 4 {
+		return time.Time{}, nil, ErrInvalidKey
+	}
+	nsec := binary.BigEndian.Uint32(data)
+	if nsec >= uint32(time.Second) {
+		return time.Time{}, nil, fmt.Errorf("invalid nanoseconds %d: %w", nsec, ErrInvalidKey)
+	}
+	return time.Unix(sec, int64(nsec)).UTC(), data[4:], nil
+}
+
+// String encodes strings with every 0x00 byte escaped as 0x00 0xff and terminated by 0x00 0x01.
+// Terminator makes the encoding self-delimiting while keeping shorter strings ordered before
+// their extensions.
+type String struct{}
+
+// Encode appends k to buf.
+func (String) Encode(buf []byte, k string) []byte {
+	return appendEscaped(buf, k)
+}
+
+// Decode decodes key from data.
+func (c String) Decode(data []byte) (string, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (String) DecodePrefix(data []byte) (string, []byte, error) {
+	v, data, err := decodeEscaped(data)
+	return string(v), data, err
+}
+
+// Bytes encodes byte slices the same way String encodes strings.
+type Bytes struct{}
+
+// Encode appends k to buf.
+func (Bytes) Encode(buf []byte, k []byte) []byte {
+	return appendEscaped(buf, k)
+}
+
+// Decode decodes key from data.
+func (c Bytes) Decode(data []byte) ([]byte, error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (Bytes) DecodePrefix(data []byte) ([]byte, []byte, error) {
+	return decodeEscaped(data)
+}
+
+// Desc returns the codec ordering keys in the order reversed to the one of c.
+// Encoding produced by c is inverted bitwise, which reverses the order because all the
+// encodings in this package are self-delimiting.
+func Desc[K any](c Codec[K]) Descending[K] {
+	return Descending[K]{c: c}
+}
+
+// Descending is the codec ordering keys in the descending order.
+type Descending[K any] struct {
+	c Codec[K]
+}
+
+// Encode appends k to buf.
+func (d Descending[K]) Encode(buf []byte, k K) []byte {
+	n := len(buf)
+	buf = d.c.Encode(buf, k)
+	invert(buf[n:])
+	return buf
+}
+
+// Decode decodes key from data.
+func (d Descending[K]) Decode(data []byte) (K, error) {
+	return decodeAll(d, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (d Descending[K]) DecodePrefix(data []byte) (K, []byte, error) {
+	inverted := bytes.Clone(data)
+	invert(inverted)
+	k, rest, err := d.c.DecodePrefix(inverted)
+	if err != nil {
+		var zero K
+		return zero, nil, err
+	}
+	return k, data[len(data)-len(rest):], nil
+}
+
+// Pair is the key composed of two elements.
+type Pair[A, B any] struct {
+	A A
+	B B
+}
+
+// Tuple2 returns the codec for pairs, ordered by the first element and then by the second one.
+func Tuple2[A, B any](a Codec[A], b Codec[B]) Tuple2Codec[A, B] {
+	return Tuple2Codec[A, B]{a: a, b: b}
+}
+
+// Tuple2Codec is the codec for pairs.
+type Tuple2Codec[A, B any] struct {
+	a Codec[A]
+	b Codec[B]
+}
+
+// Encode appends k to buf.
+func (c Tuple2Codec[A, B]) Encode(buf []byte, k Pair[A, B]) []byte {
+	return c.b.Encode(c.a.Encode(buf, k.A), k.B)
+}
+
+// EncodePrefix appends encoded first element to buf. Result is the prefix of all the keys
+// having the first element equal to a.
+func (c Tuple2Codec[A, B]) EncodePrefix(buf []byte, a A) []byte {
+	return c.a.Encode(buf, a)
+}
+
+// Decode decodes key from data.
+func (c Tuple2Codec[A, B]) Decode(data []byte) (Pair[A, B], error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (c Tuple2Codec[A, B]) DecodePrefix(data []byte) (Pair[A, B], []byte, error) {
+	var k Pair[A, B]
+	var err error
+	if k.A, data, err = c.a.DecodePrefix(data); err != nil {
+		return Pair[A, B]{}, nil, err
+	}
+	if k.B, data, err = c.b.DecodePrefix(data); err != nil {
+		return Pair[A, B]{}, nil, err
+	}
+	return k, data, nil
+}
+
+// Triple is the key composed of three elements.
+type Triple[A, B, C any] struct {
+	A A
+	B B
+	C C
+}
+
+// Tuple3 returns the codec for triples, ordered by the elements from the first to the last one.
+func Tuple3[A, B, C any](a Codec[A], b Codec[B], c Codec[C]) Tuple3Codec[A, B, C] {
+	return Tuple3Codec[A, B, C]{a: a, b: b, c: c}
+}
+
+// Tuple3Codec is the codec for triples.
+type Tuple3Codec[A, B, C any] struct {
+	a Codec[A]
+	b Codec[B]
+	c Codec[C]
+}
+
+// Encode appends k to buf.
+func (c Tuple3Codec[A, B, C]) Encode(buf []byte, k Triple[A, B, C]) []byte {
+	return c.c.Encode(c.b.Encode(c.a.Encode(buf, k.A), k.B), k.C)
+}
+
+// EncodePrefix appends encoded first element to buf. Result is the prefix of all the keys
+// having the first element equal to a.
+func (c Tuple3Codec[A, B, C]) EncodePrefix(buf []byte, a A) []byte {
+	return c.a.Encode(buf, a)
+}
+
+// EncodePrefix2 appends encoded first and second elements to buf. Result is the prefix of all
+// the keys having the first two elements equal to a and b.
+func (c Tuple3Codec[A, B, C]) EncodePrefix2(buf []byte, a A, b B) []byte {
+	return c.b.Encode(c.a.Encode(buf, a), b)
+}
+
+// Decode decodes key from data.
+func (c Tuple3Codec[A, B, C]) Decode(data []byte) (Triple[A, B, C], error) {
+	return decodeAll(c, data)
+}
+
+// DecodePrefix decodes key from the beginning of data.
+func (c Tuple3Codec[A, B, C]) DecodePrefix(data []byte) (Triple[A, B, C], []byte, error) {
+	var k Triple[A, B, C]
+	var err error
+	if k.A, data, err = c.a.DecodePrefix(data); err != nil {
+		return Triple[A, B, C]{}, nil, err
+	}
+	if k.B, data, err = c.b.DecodePrefix(data); err != nil {
+		return Triple[A, B, C]{}, nil, err
+	}
+	if k.C, data, err = c.c.DecodePrefix(data); err != nil {
+		return Triple[A, B, C]{}, nil, err
+	}
+	return k, data, nil
+}
+
+func decodeAll[K any](c Codec[K], data []byte) (K, error) {
+	k, rest, err := c.DecodePrefix(data)
+	if err != nil {
+		return k, err
+	}
+	if len(rest) > 0 {
+		var zero K
+		return zero, fmt.Errorf("%d trailing bytes: %w", len(rest), ErrInvalidKey)
+	}
+	return k, nil
+}
+
+func appendEscaped[S string | []byte](buf []byte, s S) []byte {
+	for i := range len(s) {
+		if s[i] == 0x00 {
+			buf = append(buf, 0x00, 0xff)
+			continue
+		}
+		buf = append(buf, s[i])
+	}
+	return append(buf, 0x00, 0x01)
+}
+
+func decodeEscaped(data []byte) ([]byte, []byte, error) {
+	v := []byte{}
+	for i := 0; i < len(data); i++ {
+		if data[i] != 0x00 {
+			v = append(v, data[i])
+			continue
+		}
+		if i+1 == len(data) {
+			break
+		}
+		i++
+		switch data[i] {
+		case 0x01:
+			return v, data[i+1:], nil
+		case 0xff:
+			v = append(v, 0x00)
+		default:
+			return nil, nil, fmt.Errorf("invalid escape sequence 0x00 0x%02x: %w", data[i], ErrInvalidKey)
+		}
+	}
+	return nil, nil, fmt.Errorf("missing terminator: %w", ErrInvalidKey)
+}
+
+func invert(data []byte) {
+	for i := range data {
+		data[i] = ^data[i]
+	}
+}
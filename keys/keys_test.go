@@ -0,0 +1,178 @@
+package keys
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+
+	"github.com/outofforest/iradix"
+)
+
+func requireOrder[K any](t *testing.T, c Codec[K], compare func(a, b K) int, values ...K) {
+	t.Helper()
+
+	for _, a := range values {
+		encA := c.Encode([]byte{0xaa}, a)
+		require.Equal(t, byte(0xaa), encA[0])
+
+		decoded, err := c.Decode(encA[1:])
+		require.NoError(t, err)
+		require.Equal(t, 0, compare(a, decoded), "%v decoded to %v", a, decoded)
+
+		decoded, rest, err := c.DecodePrefix(append(encA[1:], 0x01, 0x02))
+		require.NoError(t, err)
+		require.Equal(t, 0, compare(a, decoded))
+		require.Equal(t, []byte{0x01, 0x02}, rest)
+
+		_, err = c.Decode(encA[1 : len(encA)-1])
+		require.ErrorIs(t, err, ErrInvalidKey)
+
+		for _, b := range values {
+			require.Equal(t, compare(a, b), bytes.Compare(encA[1:], c.Encode(nil, b)), "%v, %v", a, b)
+		}
+	}
+}
+
+func reverse[K any](compare func(a, b K) int) func(a, b K) int {
+	return func(a, b K) int {
+		return compare(b, a)
+	}
+}
+
+func compareFloat(a, b float64) int {
+	// Negative zero is ordered before positive zero.
+	if a == 0 && b == 0 {
+		return cmp.Compare(math.Float64bits(b), math.Float64bits(a))
+	}
+	return cmp.Compare(a, b)
+}
+
+func TestIntegers(t *testing.T) {
+	rand := rand.New(rand.NewSource(1))
+
+	uints := []uint64{0, 1, 255, 256, math.MaxUint32, math.MaxUint64}
+	ints := []int64{math.MinInt64, -256, -1, 0, 1, 256, math.MaxInt64}
+	for range 50 {
+		uints = append(uints, rand.Uint64())
+		ints = append(ints, rand.Int63()-rand.Int63())
+	}
+
+	requireOrder(t, Codec[uint64](Uint[uint64]{}), cmp.Compare[uint64], uints...)
+	requireOrder(t, Codec[int64](Int[int64]{}), cmp.Compare[int64], ints...)
+	requireOrder(t, Codec[int64](Desc[int64](Int[int64]{})), reverse(cmp.Compare[int64]), ints...)
+	requireOrder(t, Codec[int8](Int[int8]{}), cmp.Compare[int8], -128, -1, 0, 1, 127)
+
+	_, err := Int[int8]{}.Decode(Int[int64]{}.Encode(nil, 128))
+	require.ErrorIs(t, err, ErrInvalidKey)
+	_, err = Uint[uint16]{}.Decode(Uint[uint64]{}.Encode(nil, math.MaxUint16+1))
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestFloats(t *testing.T) {
+	rand := rand.New(rand.NewSource(1))
+
+	floats := []float64{
+		math.Inf(-1), -math.MaxFloat64, -1, -math.SmallestNonzeroFloat64, math.Copysign(0, -1),
+		0, math.SmallestNonzeroFloat64, 1, math.MaxFloat64, math.Inf(1),
+	}
+	for range 50 {
+		floats = append(floats, rand.NormFloat64()*1e6)
+	}
+
+	requireOrder(t, Codec[float64](FloatCodec[float64]{}), compareFloat, floats...)
+	requireOrder(t, Codec[float64](Desc[float64](FloatCodec[float64]{})), reverse(compareFloat), floats...)
+	requireOrder(t, Codec[float32](FloatCodec[float32]{}), cmp.Compare[float32], -1.5, -0.25, 0.25, 1.5)
+}
+
+func TestBoolAndTime(t *testing.T) {
+	requireOrder(t, Codec[bool](Bool{}), func(a, b bool) int {
+		return cmp.Compare(lo.Ternary(a, 1, 0), lo.Ternary(b, 1, 0))
+	}, false, true)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	requireOrder(t, Codec[time.Time](Time{}), func(a, b time.Time) int { return a.Compare(b) },
+		time.Unix(-1e10, 0).UTC(), time.Unix(-1, 999999999).UTC(), time.Unix(0, 0).UTC(),
+		base, base.Add(time.Nanosecond), base.Add(time.Second), base.Add(24*time.Hour))
+}
+
+func TestStrings(t *testing.T) {
+	rand := rand.New(rand.NewSource(1))
+
+	values := []string{"", "\x00", "\x00\x00", "\x00\x01", "\x00\xff", "\x01", "a", "a\x00", "a\x00b", "ab", "\xff"}
+	for range 50 {
+		b := make([]byte, rand.Intn(5))
+		for i := range b {
+			b[i] = []byte{0x00, 0x01, 'a', 0xff}[rand.Intn(4)]
+		}
+		values = append(values, string(b))
+	}
+
+	requireOrder(t, Codec[string](String{}), strings.Compare, values...)
+	requireOrder(t, Codec[string](Desc[string](String{})), reverse(strings.Compare), values...)
+	requireOrder(t, Codec[[]byte](Bytes{}), bytes.Compare, lo.Map(values, func(v string, _ int) []byte {
+		return []byte(v)
+	})...)
+
+	_, err := String{}.Decode([]byte{'a', 0x00, 0x02})
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func TestTuples(t *testing.T) {
+	compare := func(a, b Pair[string, int64]) int {
+		return cmp.Or(strings.Compare(a.A, b.A), cmp.Compare(b.B, a.B))
+	}
+	pairs := []Pair[string, int64]{}
+	for _, s := range []string{"", "\x00", "a", "a\x00", "ab"} {
+		for _, i := range []int64{-10, 0, 10} {
+			pairs = append(pairs, Pair[string, int64]{A: s, B: i})
+		}
+	}
+	pairCodec := Tuple2[string, int64](String{}, Desc[int64](Int[int64]{}))
+	requireOrder(t, Codec[Pair[string, int64]](pairCodec), compare, pairs...)
+
+	tripleCodec := Tuple3[bool, string, float64](Bool{}, String{}, FloatCodec[float64]{})
+	requireOrder(t, Codec[Triple[bool, string, float64]](tripleCodec), func(a, b Triple[bool, string, float64]) int {
+		return cmp.Or(cmp.Compare(lo.Ternary(a.A, 1, 0), lo.Ternary(b.A, 1, 0)), strings.Compare(a.B, b.B),
+			cmp.Compare(a.C, b.C))
+	}, Triple[bool, string, float64]{A: false, B: "b", C: 1}, Triple[bool, string, float64]{A: true, B: "", C: -1},
+		Triple[bool, string, float64]{A: true, B: "a", C: -1}, Triple[bool, string, float64]{A: true, B: "a", C: 2})
+
+	require.True(t, bytes.HasPrefix(tripleCodec.Encode(nil, Triple[bool, string, float64]{A: true, B: "a", C: 2}),
+		tripleCodec.EncodePrefix2(nil, true, "a")))
+}
+
+func TestTreeRangeScan(t *testing.T) {
+	codec := Tuple2[string, int64](String{}, Int[int64]{})
+	tree := iradix.NewTree[Pair[string, int64], int](codec)
+	txn := tree.Txn()
+	for i, k := range []Pair[string, int64]{
+		{A: "a", B: 5}, {A: "a", B: -5}, {A: "a", B: -1}, {A: "a\x00", B: 0}, {A: "ab", B: -3}, {A: "", B: 7},
+	} {
+		txn.Insert(k, lo.ToPtr(i))
+	}
+	tree = txn.Commit()
+
+	var visited []Pair[string, int64]
+	require.NoError(t, tree.WalkRange(Pair[string, int64]{A: "a", B: -2}, Pair[string, int64]{A: "ab", B: 0},
+		func(k Pair[string, int64], v *int) error {
+			visited = append(visited, k)
+			return nil
+		}))
+	require.Equal(t, []Pair[string, int64]{{A: "a", B: -1}, {A: "a", B: 5}, {A: "a\x00", B: 0}, {A: "ab", B: -3}},
+		visited)
+
+	it := tree.Root().Iterator()
+	it.SeekPrefix(codec.EncodePrefix(nil, "a"))
+	var values []int
+	for v := it.Next(); v != nil; v = it.Next() {
+		values = append(values, *v)
+	}
+	require.Equal(t, []int{1, 2, 0}, values)
+}
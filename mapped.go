@@ -0,0 +1,466 @@
+package iradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// MappedTree is a read-only tree operating directly on the bytes of a snapshot written by
+// WriteSnapshot. Nodes are never deserialized, only values are decoded when returned.
+type MappedTree[T any] struct {
+	data     []byte
+	root     uint64
+	rootNode *Node[T]
+	codec    ValueCodec[T]
+	unmap    func() error
+}
+
+// OpenMappedTree maps the snapshot file into memory and returns the tree reading it.
+// Tree must be closed to release the mapping.
+func OpenMappedTree[T any](path string, codec ValueCodec[T]) (*MappedTree[T], error) {
+	data, unmap, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewMappedTree(data, codec)
+	if err != nil {
+		_ = unmap()
+		return nil, err
+	}
+	m.unmap = unmap
+	return m, nil
+}
+
+// NewMappedTree returns the tree reading snapshot stored in data. Checksum and structure of all the records
+// are verified, so traversing the tree later never fails. Values are decoded on access, and Get, iterators
+// and transactions panic if the codec fails to decode them.
+func NewMappedTree[T any](data []byte, codec ValueCodec[T]) (*MappedTree[T], error) {
+	_, rootOffset, err := openSnapshot(data, snapshotMagic)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRecords(data, rootOffset); err != nil {
+		return nil, err
+	}
+	m := &MappedTree[T]{
+		data:  data,
+		root:  rootOffset,
+		codec: codec,
+	}
+	r, err := parseNodeRecord(data, rootOffset, uint64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	m.rootNode = &Node[T]{
+		revision: r.revision,
+		prefix:   r.prefix,
+		mapped: &mappedNode[T]{
+			tree:   m,
+			record: r,
+		},
+	}
+	if r.hasValue {
+		v, err := codec.Decode(r.value)
+		if err != nil {
+			return nil, err
+		}
+		m.rootNode.setValue(v)
+	}
+	return m, nil
+}
+
+// Root returns the root node of the tree. Nodes are loaded from the mapping on first access,
+// so the returned tree may be used as the base of a transaction. Subtrees untouched by the transaction
+// keep pointing to the mapping, so the tree must not be closed as long as derived trees are in use.
+func (m *MappedTree[T]) Root() *Node[T] {
+	return m.rootNode
+}
+
+// Txn creates new transaction modifying the mapped tree.
+func (m *MappedTree[T]) Txn() *Txn[T] {
+	return NewTxn(m.rootNode)
+}
+
+// Close releases the mapping. Tree, its iterators and trees derived from it must not be used afterwards.
+func (m *MappedTree[T]) Close() error {
+	if m.unmap == nil {
+		return nil
+	}
+	unmap := m.unmap
+	m.unmap = nil
+	m.data = nil
+	return unmap()
+}
+
+// Get traverses nodes to find the value of key.
+func (m *MappedTree[T]) Get(k []byte) *T {
+	n := m.record(m.root)
+	search := k
+	for {
+		// Check for key exhaustion
+		if len(search) == 0 {
+			return m.value(n)
+		}
+
+		// Look for an edge.
+		idx, found := slices.BinarySearch(n.labels, search[0])
+		if !found {
+			return nil
+		}
+		n = m.record(n.child(idx))
+
+		// Consume the search prefix.
+		if !bytes.HasPrefix(search, n.prefix) {
+			return nil
+		}
+
+		search = search[len(n.prefix):]
+	}
+}
+
+// Iterator is used to return an iterator at
+// the given node to walk the tree.
+func (m *MappedTree[T]) Iterator() *MappedIterator[T] {
+	return &MappedIterator[T]{
+		tree:  m,
+		node:  m.root,
+		valid: true,
+	}
+}
+
+// verifyRecords verifies the records stored between the header and the trailer. Every child must be stored
+// before its parent, so traversing the tree always terminates, and labels must be strictly increasing and equal
+// to the first byte of the child prefix, so edges might be searched and loaded without further checks.
+func verifyRecords(data []byte, rootOffset uint64) error {
+	end := uint64(len(data) - trailerSize)
+
+	// starts marks the offsets at which records begin, so children can't point into the middle of a record.
+	starts := make([]uint64, end/64+1)
+	offset := uint64(headerSize)
+	for offset < end {
+		r, err := parseNodeRecord(data, offset, end)
+		if err != nil {
+			return err
+		}
+		for i, label := range r.labels {
+			if i > 0 && label <= r.labels[i-1] {
+				return fmt.Errorf("labels are not sorted: %w", ErrCorrupted)
+			}
+			child := r.child(i)
+			if child >= offset || starts[child/64]&(1<<(child%64)) == 0 {
+				return fmt.Errorf("invalid child offset: %w", ErrCorrupted)
+			}
+			cr, err := parseNodeRecord(data, child, offset)
+			if err != nil {
+				return err
+			}
+			if len(cr.prefix) == 0 || cr.prefix[0] != label {
+				return fmt.Errorf("invalid edge label: %w", ErrCorrupted)
+			}
+		}
+		starts[offset/64] |= 1 << (offset % 64)
+
+		// Root is stored last.
+		if offset+r.size == end {
+			if offset != rootOffset {
+				return fmt.Errorf("invalid root offset: %w", ErrCorrupted)
+			}
+			return nil
+		}
+		offset += r.size
+	}
+	return fmt.Errorf("snapshot has no records: %w", ErrCorrupted)
+}
+
+// record parses the record at offset. Records are verified when the tree is created, so parsing never fails.
+func (m *MappedTree[T]) record(offset uint64) nodeRecord {
+	r, err := parseNodeRecord(m.data, offset, uint64(len(m.data)))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (m *MappedTree[T]) value(r nodeRecord) *T {
+	if !r.hasValue {
+		return nil
+	}
+	v, err := m.codec.Decode(r.value)
+	if err != nil {
+		panic(fmt.Errorf("decoding value failed: %w", err))
+	}
+	return v
+}
+
+// edges creates nodes for the children of the record.
+func (m *MappedTree[T]) edges(r nodeRecord) edges[T] {
+	var es edges[T]
+	for i, label := range r.labels {
+		cr := m.record(r.child(i))
+		child := &Node[T]{
+			revision: cr.revision,
+			prefix:   cr.prefix,
+		}
+		if v := m.value(cr); v != nil {
+			child.setValue(v)
+		}
+		if len(cr.labels) > 0 {
+			child.mapped = &mappedNode[T]{
+				tree:   m,
+				record: cr,
+			}
+		}
+		es.add(edge[T]{label: label, node: child}, nil)
+	}
+	return es
+}
+
+type mappedItem struct {
+	children       []byte
+	index1, index2 int
+
+	// keyLen is the length of the key of the node owning the children.
+	keyLen int
+}
+
+func (itm mappedItem) len() int {
+	return len(itm.children) / 8
+}
+
+func (itm mappedItem) child(i int) uint64 {
+	return binary.LittleEndian.Uint64(itm.children[8*i:])
+}
+
+// MappedIterator is used to iterate over the values of the mapped tree
+// in pre-order.
+type MappedIterator[T any] struct {
+	tree  *MappedTree[T]
+	node  uint64
+	valid bool
+	stack []mappedItem
+	skip  int
+
+	// key holds the key of the last visited node. Its first base bytes are the key of the parent of node,
+	// and the first keyLen bytes are the key of the node owning the children of every stack item.
+	key  []byte
+	base int
+}
+
+// SeekPrefix is used to seek the iterator to a given prefix.
+func (i *MappedIterator[T]) SeekPrefix(prefix []byte) {
+	// Wipe the stack
+	i.stack = nil
+	n := i.tree.record(i.node)
+	search := prefix
+	for {
+		// Check for key exhaustion.
+		if len(search) == 0 {
+			i.skip = len(n.prefix)
+			return
+		}
+
+		// Look for an edge.
+		idx, found := slices.BinarySearch(n.labels, search[0])
+		if !found {
+			i.valid = false
+			return
+		}
+		i.key = append(i.key[:i.base], n.prefix...)
+		i.base = len(i.key)
+		i.node = n.child(idx)
+		n = i.tree.record(i.node)
+		switch {
+		case bytes.HasPrefix(search, n.prefix):
+			search = search[len(n.prefix):]
+		case bytes.HasPrefix(n.prefix, search):
+			i.skip = len(search)
+			return
+		default:
+			i.valid = false
+			return
+		}
+	}
+}
+
+// SeekLowerBound is used to seek the iterator to the smallest key that is
+// greater or equal to the given key.
+func (i *MappedIterator[T]) SeekLowerBound(key []byte) {
+	if !i.valid {
+		return
+	}
+	i.initStack()
+
+	for len(i.stack) > 0 {
+		n := i.peek()
+
+		// Only the first item of the stack refers to the node returned by SeekPrefix.
+		prefix := n.prefix
+		if len(i.stack) == 1 {
+			prefix = prefix[i.skip:]
+		}
+
+		cmp := key
+		if len(prefix) < len(key) {
+			cmp = key[:len(prefix)]
+		}
+
+		prefixCmp := bytes.Compare(prefix, cmp)
+
+		if prefixCmp == 0 && len(prefix) == len(key) {
+			return
+		}
+
+		if prefixCmp > 0 && n.hasValue {
+			return
+		}
+
+		i.pop()
+		if prefixCmp > 0 {
+			i.findMin(n)
+			return
+		}
+
+		if prefixCmp < 0 {
+			return
+		}
+
+		// Consume the search prefix. See Iterator.SeekLowerBound for the explanation.
+		key = key[len(prefix):]
+
+		idx, _ := slices.BinarySearch(n.labels, key[0])
+		if idx == len(n.labels) {
+			return
+		}
+
+		i.push(n, idx)
+	}
+}
+
+// Next returns the next value in order.
+func (i *MappedIterator[T]) Next() *T {
+	_, v := i.NextKey()
+	return v
+}
+
+// NextKey works like Next but returns the key of the value too. Key is valid until the iterator is used again.
+func (i *MappedIterator[T]) NextKey() ([]byte, *T) {
+	if i.stack == nil && i.valid {
+		i.initStack()
+	}
+
+	for len(i.stack) > 0 {
+		if n, ok := i.forward(); ok && n.hasValue {
+			return i.key, i.tree.value(n)
+		}
+	}
+	return nil, nil
+}
+
+// Back moves iterator back.
+func (i *MappedIterator[T]) Back(count uint64) {
+	for len(i.stack) > 0 && count > 0 {
+		if n, ok := i.backward(); ok && n.hasValue {
+			count--
+		}
+	}
+}
+
+func (i *MappedIterator[T]) peek() nodeRecord {
+	itm := i.stack[len(i.stack)-1]
+	return i.tree.record(itm.child(itm.index1))
+}
+
+func (i *MappedIterator[T]) pop() {
+	i.stack[len(i.stack)-1].index1++
+	i.stack[len(i.stack)-1].index2++
+}
+
+func (i *MappedIterator[T]) forward() (nodeRecord, bool) {
+	itm := &i.stack[len(i.stack)-1]
+	if itm.index2 == itm.len() {
+		i.stack = i.stack[:len(i.stack)-1]
+		if len(i.stack) > 0 {
+			itm := &i.stack[len(i.stack)-1]
+			itm.index2 = itm.index1
+		}
+		return nodeRecord{}, false
+	}
+
+	n := i.tree.record(itm.child(itm.index2))
+	itm.index1++
+	itm.index2++
+
+	if len(n.labels) > 0 {
+		i.push(n, 0)
+	} else {
+		i.key = append(i.key[:itm.keyLen], n.prefix...)
+	}
+
+	return n, true
+}
+
+func (i *MappedIterator[T]) backward() (nodeRecord, bool) {
+	itm := &i.stack[len(i.stack)-1]
+	if itm.index1 == 0 {
+		i.stack = i.stack[:len(i.stack)-1]
+		if len(i.stack) == 0 {
+			i.stack = nil
+			return nodeRecord{}, false
+		}
+
+		itm := &i.stack[len(i.stack)-1]
+		if itm.index1 == itm.index2 {
+			itm.index1--
+			itm.index2--
+			return i.tree.record(itm.child(itm.index1)), true
+		}
+		return nodeRecord{}, false
+	}
+
+	if itm.index1 == itm.index2 {
+		itm.index2--
+		n := i.tree.record(itm.child(itm.index2))
+		if len(n.labels) > 0 {
+			i.push(n, len(n.labels))
+			return nodeRecord{}, false
+		}
+	}
+
+	itm.index1--
+	return i.tree.record(itm.child(itm.index1)), true
+}
+
+func (i *MappedIterator[T]) findMin(n nodeRecord) {
+	for {
+		i.push(n, 0)
+		n = i.tree.record(n.child(0))
+		if n.hasValue {
+			return
+		}
+		i.pop()
+	}
+}
+
+func (i *MappedIterator[T]) initStack() {
+	i.stack = []mappedItem{
+		{
+			children: binary.LittleEndian.AppendUint64(nil, i.node),
+			index1:   0,
+			index2:   0,
+			keyLen:   i.base,
+		},
+	}
+}
+
+// push puts children of the node taken from the top stack item on the stack, positioned at index.
+func (i *MappedIterator[T]) push(n nodeRecord, index int) {
+	i.key = append(i.key[:i.stack[len(i.stack)-1].keyLen], n.prefix...)
+	i.stack = append(i.stack, mappedItem{
+		children: n.children,
+		index1:   index,
+		index2:   index,
+		keyLen:   len(i.key),
+	})
+}
@@ -0,0 +1,294 @@
+package iradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	mathrand "math/rand"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stringCodec struct{}
+
+func (stringCodec) Encode(buf []byte, v *string) []byte {
+	return append(buf, *v...)
+}
+
+func (stringCodec) Decode(data []byte) (*string, error) {
+	v := string(data)
+	return &v, nil
+}
+
+func tempSnapshotFile[T any](t *testing.T, root *Node[T], codec ValueCodec[T]) string {
+	path := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, writeSnapshotFile(path, root, codec))
+	return path
+}
+
+func iterateAll[T any](next func() *T) []T {
+	var out []T
+	for v := next(); v != nil; v = next() {
+		out = append(out, *v)
+	}
+	return out
+}
+
+func TestMappedTree(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	txn := NewTxn(New[string]())
+	var keys []string
+	for range 500 {
+		k := randString(rand)
+		keys = append(keys, k)
+		txn.Insert([]byte(k), &k)
+	}
+	r := txn.Commit()
+
+	m, err := OpenMappedTree[string](tempSnapshotFile[string](t, r, stringCodec{}), stringCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	for range 200 {
+		k := []byte(randString(rand))
+		require.Equal(t, r.Get(k), m.Get(k))
+	}
+	for _, k := range keys {
+		require.Equal(t, k, *m.Get([]byte(k)))
+	}
+
+	require.Equal(t, iterateAll(r.Iterator().Next), iterateAll(m.Iterator().Next))
+
+	for range 200 {
+		prefix := []byte(randString(rand))
+		prefix = prefix[:min(len(prefix), rand.Intn(3))]
+		lowerBound := []byte(randString(rand))
+		back := uint64(rand.Intn(5))
+
+		it1 := r.Iterator()
+		it2 := m.Iterator()
+		it1.SeekPrefix(prefix)
+		it2.SeekPrefix(prefix)
+		require.Equal(t, iterateAll(it1.Next), iterateAll(it2.Next), "prefix %q", prefix)
+
+		it1 = r.Iterator()
+		it2 = m.Iterator()
+		it1.SeekLowerBound(lowerBound)
+		it2.SeekLowerBound(lowerBound)
+		require.Equal(t, iterateAll(it1.Next), iterateAll(it2.Next), "lower bound %q", lowerBound)
+
+		it1 = r.Iterator()
+		it2 = m.Iterator()
+		it1.SeekPrefix(prefix)
+		it2.SeekPrefix(prefix)
+		it1.SeekLowerBound(lowerBound)
+		it2.SeekLowerBound(lowerBound)
+		require.Equal(t, iterateAll(it1.Next), iterateAll(it2.Next), "prefix %q, lower bound %q", prefix, lowerBound)
+
+		it1 = r.Iterator()
+		it2 = m.Iterator()
+		it1.SeekLowerBound(lowerBound)
+		it2.SeekLowerBound(lowerBound)
+		it1.Next()
+		it2.Next()
+		it1.Back(back)
+		it2.Back(back)
+		require.Equal(t, iterateAll(it1.Next), iterateAll(it2.Next), "lower bound %q, back %d", lowerBound, back)
+
+		// Values are equal to keys.
+		it2 = m.Iterator()
+		it2.SeekPrefix(prefix)
+		it2.SeekLowerBound(lowerBound)
+		it2.Next()
+		it2.Back(back)
+		for k, v := it2.NextKey(); v != nil; k, v = it2.NextKey() {
+			require.Equal(t, *v, string(k))
+		}
+	}
+
+	t.Run("seek lower bound past subtree", func(t *testing.T) {
+		testMappedTreeSeekLowerBoundBack(t)
+	})
+}
+
+func testMappedTreeSeekLowerBoundBack(t *testing.T) {
+	keys := []string{"a1", "abc", "barbazboo", "f", "foo", "found", "zap", "zip"}
+	txn := NewTxn(New[string]())
+	for _, k := range keys {
+		txn.Insert([]byte(k), &k)
+	}
+	r := txn.Commit()
+
+	m, err := OpenMappedTree[string](tempSnapshotFile[string](t, r, stringCodec{}), stringCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	// Lower bound is placed after the "found" subtree, moving back past the beginning starts
+	// iteration from the first key.
+	for back, want := range map[uint64][]string{
+		2:  {"foo", "found", "zap", "zip"},
+		10: keys,
+	} {
+		it := m.Iterator()
+		it.SeekLowerBound([]byte("founz"))
+		it.Back(back)
+		require.Equal(t, want, iterateAll(it.Next), "back %d", back)
+	}
+}
+
+func TestMappedTreeCorrupted(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteSnapshot[int](buf, buildTree("a", "b"), intCodec{}))
+	data := buf.Bytes()
+	data[len(data)/2] ^= 0x01
+
+	_, err := NewMappedTree[int](data, intCodec{})
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestMappedTreeInvalidStructure(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(data []byte, root int)
+		err    string
+	}{
+		{
+			name: "cycle",
+			modify: func(data []byte, root int) {
+				binary.LittleEndian.PutUint64(data[root+nodeRecordSize+2:], uint64(root))
+			},
+			err: "invalid child offset",
+		},
+		{
+			name: "child in the middle of record",
+			modify: func(data []byte, root int) {
+				binary.LittleEndian.PutUint64(data[root+nodeRecordSize+2:], headerSize+1)
+			},
+			err: "invalid child offset",
+		},
+		{
+			name: "unsorted labels",
+			modify: func(data []byte, root int) {
+				// Children are swapped too, so labels still match the prefixes.
+				children := data[root+nodeRecordSize+2:]
+				data[root+nodeRecordSize], data[root+nodeRecordSize+1] = 'b', 'a'
+				a, b := binary.LittleEndian.Uint64(children), binary.LittleEndian.Uint64(children[8:])
+				binary.LittleEndian.PutUint64(children, b)
+				binary.LittleEndian.PutUint64(children[8:], a)
+			},
+			err: "labels are not sorted",
+		},
+		{
+			name: "duplicated labels",
+			modify: func(data []byte, root int) {
+				data[root+nodeRecordSize+1] = 'a'
+			},
+			err: "labels are not sorted",
+		},
+		{
+			name: "label not matching prefix",
+			modify: func(data []byte, root int) {
+				data[root+nodeRecordSize+1] = 'c'
+			},
+			err: "invalid edge label",
+		},
+		{
+			name: "root not stored last",
+			modify: func(data []byte, root int) {
+				binary.LittleEndian.PutUint64(data[len(data)-trailerSize:], headerSize)
+			},
+			err: "invalid root offset",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, WriteSnapshot[int](buf, buildTree("a", "b"), intCodec{}))
+			data := buf.Bytes()
+			root := int(binary.LittleEndian.Uint64(data[len(data)-trailerSize:]))
+
+			test.modify(data, root)
+			binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.Checksum(data[:len(data)-4], crcTable))
+
+			_, err := NewMappedTree[int](data, intCodec{})
+			require.ErrorIs(t, err, ErrCorrupted)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestMappedTreeTxn(t *testing.T) {
+	txn := NewTxn(New[int]())
+	for i := range 1000 {
+		txn.Insert([]byte(fmt.Sprintf("key/%04d", i)), lo.ToPtr(i))
+	}
+	r := txn.Commit()
+
+	m, err := OpenMappedTree[int](tempSnapshotFile[int](t, r, intCodec{}), intCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	txn = NewTxn(r)
+	mTxn := m.Txn()
+	for _, txn := range []*Txn[int]{txn, mTxn} {
+		txn.Insert([]byte("key/0500"), lo.ToPtr(-1))
+		txn.Insert([]byte("key/1000"), lo.ToPtr(1000))
+		txn.Insert([]byte("other"), lo.ToPtr(1001))
+		txn.Delete([]byte("key/0010"))
+	}
+	r = txn.Commit()
+	hybrid := mTxn.Commit()
+
+	require.Equal(t, collect(t, r), collect(t, hybrid))
+	require.Equal(t, iterateAll(r.Iterator().Next), iterateAll(hybrid.Iterator().Next))
+
+	// Mapped tree is not affected by the transaction.
+	require.Equal(t, 500, *m.Get([]byte("key/0500")))
+	require.Nil(t, m.Get([]byte("key/1000")))
+	require.Equal(t, 10, *m.Root().Get([]byte("key/0010")))
+
+	// Untouched subtrees are still backed by the mapping.
+	_, child := hybrid.nodeAt([]byte("key/0")).getEdge('9')
+	require.NotNil(t, child.mapped)
+
+	// Hybrid tree may be written as a new file.
+	m2, err := OpenMappedTree[int](tempSnapshotFile[int](t, hybrid, intCodec{}), intCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m2.Close())
+	}()
+	require.Equal(t, iterateAll(r.Iterator().Next), iterateAll(m2.Iterator().Next))
+}
+
+func TestMappedTreeConcurrentLoad(t *testing.T) {
+	r := buildTree("a", "ab", "abc", "b", "ba", "c")
+	m, err := OpenMappedTree[int](tempSnapshotFile[int](t, r, intCodec{}), intCodec{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, m.Close())
+	}()
+
+	expected := iterateAll(r.Iterator().Next)
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, expected, iterateAll(m.Root().Iterator().Next))
+		}()
+	}
+	wg.Wait()
+}
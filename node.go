@@ -2,36 +2,123 @@ package iradix
 
 import (
 	"bytes"
+	"sync"
+	"sync/atomic"
 )
 
+// inlinePrefixSize is the maximum length of the prefix stored inside the node.
+const inlinePrefixSize = 16
+
 // edge is used to represent an edge node.
 type edge[T any] struct {
 	label byte
 	node  *Node[T]
 }
 
-// Node is an immutable node in the radix tree.
+// lastValueID is the identifier of the last value stored in any node.
+var lastValueID atomic.Uint64
+
+// Node is an immutable node in the radix tree. Values are stored inline, so small values don't require
+// allocations, but they are copied together with the nodes on the path to every modified key, and nodes
+// without values take space for them too. Trees of large values should use pointers as values, T = *V,
+// so only the pointers are copied (see BenchmarkLargeValues).
 type Node[T any] struct {
-	revision uint64
+	// Fields read while iterating are kept at the beginning, so they share the cache line.
 
-	value *T
+	// valueID identifies the value stored in the node, zero means there is no value. Value keeps its
+	// identifier when the node is copied and gets new one whenever it is set, so it is used to detect
+	// changed values without comparing them.
+	valueID uint64
 
-	// prefix is the common prefix we ignore.
-	prefix []byte
+	// value is stored inline, so setting it does not allocate. It must not be the last field, because
+	// zero-sized last field is padded, and sets would pay for values they don't store.
+	value T
+
+	// mapped is set if edges are loaded lazily from the mapped tree.
+	mapped *mappedNode[T]
 
 	// Edges should be stored in-order for iteration.
-	// We avoid a fully materialized slice to save memory,
-	// since in most cases we expect to be sparse.
+	// Layout of edges adapts to their number, so sparse nodes
+	// stay small and dense ones are searched in constant time.
 	edges edges[T]
+
+	revision uint64
+
+	// prefix is the common prefix we ignore.
+	prefix []byte
+
+	// inlinePrefix keeps short prefixes, so they don't require separate allocation.
+	inlinePrefix [inlinePrefixSize]byte
+
+	// hash caches the hash of the subtree.
+	hash atomic.Pointer[Hash]
+}
+
+// mappedNode references the record of the mapped tree from which the node edges are loaded.
+type mappedNode[T any] struct {
+	once   sync.Once
+	tree   *MappedTree[T]
+	record nodeRecord
+}
+
+// setPrefix sets the copy of prefix as the prefix of the node. Short prefixes are stored inline.
+// Prefix passed might overlap with the current one.
+func (n *Node[T]) setPrefix(prefix []byte) {
+	switch {
+	case len(prefix) == 0:
+		clear(n.inlinePrefix[:])
+		n.prefix = nil
+	case len(prefix) <= inlinePrefixSize:
+		// Remaining bytes are cleared, so nodes storing equal prefixes are equal.
+		size := copy(n.inlinePrefix[:], prefix)
+		clear(n.inlinePrefix[size:])
+		n.prefix = n.inlinePrefix[:size]
+	default:
+		n.prefix = copyPrefix(prefix)
+	}
 }
 
-// Get traverses nodes to find the value of key.
+// hasValue returns true if the value is stored in the node.
+func (n *Node[T]) hasValue() bool {
+	return n.valueID != 0
+}
+
+// valueRef returns the pointer to the value stored in the node, or nil if there is no value.
+func (n *Node[T]) valueRef() *T {
+	if n.valueID == 0 {
+		return nil
+	}
+	return &n.value
+}
+
+// setValue stores the copy of v in the node under new identifier.
+func (n *Node[T]) setValue(v *T) {
+	n.value = *v
+	n.valueID = lastValueID.Add(1)
+}
+
+// copyValue stores the value of src in the node, keeping its identifier.
+func (n *Node[T]) copyValue(src *Node[T]) {
+	n.value = src.value
+	n.valueID = src.valueID
+}
+
+// clearValue removes the value, releasing everything it references.
+func (n *Node[T]) clearValue() {
+	var zero T
+	n.value = zero
+	n.valueID = 0
+}
+
+// Get traverses nodes to find the value of key. Returned pointer points to the value stored
+// in the node, so it must not be modified. It is not the pointer passed to Insert, and the nodes of
+// different versions of the tree return different pointers for the same value.
 func (n *Node[T]) Get(k []byte) *T {
 	search := k
 	for {
 		// Check for key exhaustion
 		if len(search) == 0 {
-			return n.value
+			return n.valueRef()
 		}
 
 		// Look for an edge.
@@ -49,75 +136,103 @@ func (n *Node[T]) Get(k []byte) *T {
 	}
 }
 
+// Lookup returns the value of key and true, or zero value and false if key does not exist.
+func (n *Node[T]) Lookup(k []byte) (T, bool) {
+	return deref(n.Get(k))
+}
+
 // Iterator is used to return an iterator at
 // the given node to walk the tree.
 func (n *Node[T]) Iterator() *Iterator[T] {
 	return &Iterator[T]{node: n}
 }
 
-func (n *Node[T]) addEdge(e edge[T]) {
-	num := len(n.edges)
-	idx := search[T](n.edges, e.label)
-	n.edges = append(n.edges, e)
-	if idx != num {
-		copy(n.edges[idx+1:], n.edges[idx:num])
-		n.edges[idx] = e
+// children returns the edges of the node. All the reads of edges must go through this method
+// because edges of the nodes backed by the mapped tree are loaded on first access.
+func (n *Node[T]) children() *edges[T] {
+	if n.mapped != nil {
+		n.mapped.once.Do(func() {
+			n.edges = n.mapped.tree.edges(n.mapped.record)
+		})
 	}
+	return &n.edges
+}
+
+func (n *Node[T]) addEdge(e edge[T], a *arena[T]) {
+	n.edges.add(e, a)
 }
 
 func (n *Node[T]) replaceEdge(e edge[T]) {
-	num := len(n.edges)
-	idx := search[T](n.edges, e.label)
-	if idx < num && n.edges[idx].label == e.label {
-		n.edges[idx].node = e.node
-		return
+	pos := n.edges.find(e.label)
+	if pos < 0 {
+		panic("replacing missing edge")
 	}
-	panic("replacing missing edge")
+	*n.edges.ref(pos) = e.node
 }
 
 func (n *Node[T]) getEdge(label byte) (int, *Node[T]) {
-	num := len(n.edges)
-	idx := search(n.edges, label)
-	if idx < num && n.edges[idx].label == label {
-		return idx, n.edges[idx].node
+	es := n.children()
+	if pos := es.find(label); pos >= 0 {
+		return pos, es.at(pos).node
 	}
 	return -1, nil
 }
 
-func (n *Node[T]) delEdge(label byte) {
-	num := len(n.edges)
-	idx := search(n.edges, label)
-	if idx < num && n.edges[idx].label == label {
-		copy(n.edges[idx:], n.edges[idx+1:])
-		n.edges[len(n.edges)-1] = edge[T]{}
-		n.edges = n.edges[:len(n.edges)-1]
-	}
+func (n *Node[T]) delEdge(label byte, a *arena[T]) {
+	n.edges.del(label, a)
 }
 
 func (n *Node[T]) getLowerBoundEdge(label byte) (int, *Node[T]) {
-	idx := search(n.edges, label)
+	es := n.children()
 	// we want lower bound behavior so return even if it's not an exact match
-	if idx < len(n.edges) {
-		return idx, n.edges[idx].node
+	if pos := es.lowerBound(label); pos < es.end() {
+		return pos, es.at(pos).node
 	}
 	return -1, nil
 }
 
-// rawIterator is used to return a raw iterator at the given node to walk the
-// tree.
-func search[T any](es edges[T], label byte) int {
-	// Define f(-1) == false and f(n) == true.
-	// Invariant: f(i-1) == false, f(j) == true.
-	i, j := 0, len(es)
-	for i < j {
-		h := int(uint(i+j) >> 1) // avoid overflow when computing h.
-		// i ≤ h < j
-		if es[h].label < label {
-			i = h + 1 // preserves f(i-1) == false
-		} else {
-			j = h // preserves f(j) == true
+// walk calls fn for each value stored in the tree with key in range [from, to), in key order.
+// Nil to means there is no upper bound. Key passed to fn is valid only until fn returns.
+func (n *Node[T]) walk(from, to []byte, fn func(k []byte, v *T) error) error {
+	_, err := n.walkNode(make([]byte, 0, 64), from, to, fn)
+	return err
+}
+
+func (n *Node[T]) walkNode(key, from, to []byte, fn func(k []byte, v *T) error) (bool, error) {
+	key = append(key, n.prefix...)
+	if to != nil && bytes.Compare(key, to) >= 0 {
+		return false, nil
+	}
+	// All the keys in this subtree start with key, so the subtree is skipped if key is lower
+	// than from, unless from goes through this node.
+	if !bytes.HasPrefix(from, key) && bytes.Compare(key, from) < 0 {
+		return true, nil
+	}
+
+	if n.hasValue() && bytes.Compare(key, from) >= 0 {
+		if err := fn(key, &n.value); err != nil {
+			return false, err
+		}
+	}
+	for _, child := range n.children().all() {
+		if cont, err := child.walkNode(key, from, to, fn); !cont || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// nodeAt returns the node for which the concatenation of prefixes on the path from n is equal to path.
+func (n *Node[T]) nodeAt(path []byte) *Node[T] {
+	if !bytes.HasPrefix(path, n.prefix) {
+		return nil
+	}
+	path = path[len(n.prefix):]
+	for len(path) > 0 {
+		if _, n = n.getEdge(path[0]); n == nil || !bytes.HasPrefix(path, n.prefix) {
+			return nil
 		}
+		path = path[len(n.prefix):]
 	}
-	// i == j, f(i-1) == false, and f(j) (= f(i)) == true  =>  answer is i.
-	return i
+	return n
 }
@@ -0,0 +1,126 @@
+package iradix
+
+import (
+	"errors"
+)
+
+// ErrConflict is returned if optimistic transaction can't be committed because data it depends on
+// has been modified by another transaction.
+var ErrConflict = errors.New("transaction conflicts with concurrent changes")
+
+// errModified stops the diff on the first change found.
+var errModified = errors.New("modified")
+
+type optimisticWrite[T any] struct {
+	key   []byte
+	value *T
+}
+
+// OptimisticTxn is the transaction which doesn't block other writers of the store. It records
+// the keys and prefixes it reads and writes. On commit, they are validated against the latest root
+// of the store. If none of them has been modified since the transaction was started, its writes are
+// rebased on top of the latest root, otherwise ErrConflict is returned.
+// A transaction is not thread safe, and should only be used by a single goroutine.
+type OptimisticTxn[T any] struct {
+	store    *Store[T]
+	txn      *Txn[T]
+	keys     [][]byte
+	prefixes [][]byte
+	writes   []optimisticWrite[T]
+}
+
+// Begin starts the optimistic transaction on the current root of the store.
+func (s *Store[T]) Begin() *OptimisticTxn[T] {
+	return &OptimisticTxn[T]{
+		store: s,
+		txn:   NewTxn(s.root.Load()),
+	}
+}
+
+// Get is used to lookup a specific key, returning the value if it was found.
+// Key is recorded as read.
+func (t *OptimisticTxn[T]) Get(k []byte) *T {
+	t.keys = append(t.keys, copyPrefix(k))
+	return t.txn.Get(k)
+}
+
+// Iterator returns the iterator over the keys having the prefix. Prefix is recorded as read.
+func (t *OptimisticTxn[T]) Iterator(prefix []byte) *Iterator[T] {
+	t.prefixes = append(t.prefixes, copyPrefix(prefix))
+	it := t.txn.Root().Iterator()
+	it.SeekPrefix(prefix)
+	return it
+}
+
+// Insert is used to add or update a given key. Previous value is returned.
+// Key is recorded as written.
+func (t *OptimisticTxn[T]) Insert(k []byte, v *T) *T {
+	t.keys = append(t.keys, copyPrefix(k))
+	t.writes = append(t.writes, optimisticWrite[T]{key: copyPrefix(k), value: v})
+	return t.txn.Insert(k, v)
+}
+
+// Delete is used to delete a given key. Previous value is returned.
+// Key is recorded as written.
+func (t *OptimisticTxn[T]) Delete(k []byte) *T {
+	t.keys = append(t.keys, copyPrefix(k))
+	t.writes = append(t.writes, optimisticWrite[T]{key: copyPrefix(k)})
+	return t.txn.Delete(k)
+}
+
+// Commit validates the transaction against the latest root of the store and commits it.
+// ErrConflict is returned if any of the keys or prefixes read or written by the transaction
+// has been modified since the transaction was started.
+func (t *OptimisticTxn[T]) Commit() (*Node[T], error) {
+	s := t.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	base := t.txn.base
+	latest := s.root.Load()
+	if latest == base {
+		return s.commit(t.txn)
+	}
+
+	for _, k := range t.keys {
+		if valueIDAt(base, k) != valueIDAt(latest, k) {
+			return nil, ErrConflict
+		}
+	}
+	for _, p := range t.prefixes {
+		if prefixModified(base, latest, p) {
+			return nil, ErrConflict
+		}
+	}
+
+	txn := NewTxn(latest)
+	for _, w := range t.writes {
+		if w.value == nil {
+			txn.Delete(w.key)
+		} else {
+			txn.Insert(w.key, w.value)
+		}
+	}
+	return s.commit(txn)
+}
+
+// valueIDAt returns the identifier of the value stored under the key, or zero if key does not exist.
+// Values are stored in the nodes, so pointers returned by Get change whenever the node is copied, even if
+// the value is not modified.
+func valueIDAt[T any](root *Node[T], k []byte) uint64 {
+	if n := root.nodeAt(k); n != nil {
+		return n.valueID
+	}
+	return 0
+}
+
+// prefixModified checks if any key having the prefix differs between trees.
+func prefixModified[T any](a, b *Node[T], prefix []byte) bool {
+	return diffPrefix(a, b, prefix, func([]byte, *T, *T) error {
+		return errModified
+	}) != nil
+}
@@ -0,0 +1,151 @@
+package iradix
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptimisticTxnRebase(t *testing.T) {
+	s := NewStore(buildTree("a/1", "b/1"))
+
+	txn := s.Begin()
+	require.Equal(t, 0, *txn.Get([]byte("a/1")))
+	require.Len(t, iterateAll(txn.Iterator([]byte("a/")).Next), 1)
+	txn.Insert([]byte("a/2"), lo.ToPtr(10))
+
+	// Concurrent change outside the read set.
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("b/2"), lo.ToPtr(20))
+		txn.Delete([]byte("b/1"))
+		return nil
+	}))
+
+	root, err := txn.Commit()
+	require.NoError(t, err)
+	require.Same(t, root, s.Root())
+	require.Equal(t, map[string]int{"a/1": 0, "a/2": 10, "b/2": 20}, collect(t, root))
+}
+
+func TestOptimisticTxnSubtreeModified(t *testing.T) {
+	s := NewStore(buildTree("a", "b"))
+
+	txn := s.Begin()
+	require.Equal(t, 0, *txn.Get([]byte("a")))
+	txn.Insert([]byte("c"), lo.ToPtr(10))
+
+	// Concurrent change copies the node storing the read key, but doesn't modify its value.
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("ab"), lo.ToPtr(20))
+		return nil
+	}))
+
+	root, err := txn.Commit()
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 0, "ab": 20, "b": 1, "c": 10}, collect(t, root))
+}
+
+func TestOptimisticTxnConflicts(t *testing.T) {
+	cases := []struct {
+		name   string
+		txn    func(txn *OptimisticTxn[int])
+		update func(txn *Txn[int])
+	}{
+		{
+			name:   "read key modified",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Get([]byte("a/1")) },
+			update: func(txn *Txn[int]) { txn.Insert([]byte("a/1"), lo.ToPtr(1)) },
+		},
+		{
+			name:   "read missing key inserted",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Get([]byte("c")) },
+			update: func(txn *Txn[int]) { txn.Insert([]byte("c"), lo.ToPtr(1)) },
+		},
+		{
+			name:   "written key deleted",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Insert([]byte("b/1"), lo.ToPtr(1)) },
+			update: func(txn *Txn[int]) { txn.Delete([]byte("b/1")) },
+		},
+		{
+			name:   "key inserted under read prefix",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Iterator([]byte("a/")) },
+			update: func(txn *Txn[int]) { txn.Insert([]byte("a/3"), lo.ToPtr(1)) },
+		},
+		{
+			name:   "key inserted under empty prefix",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Iterator([]byte("c/")) },
+			update: func(txn *Txn[int]) { txn.Insert([]byte("c/1"), lo.ToPtr(1)) },
+		},
+		{
+			name:   "key deleted under read prefix",
+			txn:    func(txn *OptimisticTxn[int]) { txn.Iterator([]byte("a")) },
+			update: func(txn *Txn[int]) { txn.Delete([]byte("a/2")) },
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewStore(buildTree("a/1", "a/2", "b/1"))
+			txn := s.Begin()
+			tc.txn(txn)
+			txn.Insert([]byte("x"), lo.ToPtr(1))
+
+			require.NoError(t, s.Update(func(txn *Txn[int]) error {
+				tc.update(txn)
+				return nil
+			}))
+			root := s.Root()
+
+			_, err := txn.Commit()
+			require.ErrorIs(t, err, ErrConflict)
+			require.Same(t, root, s.Root())
+		})
+	}
+}
+
+func TestOptimisticTxnConcurrentCounters(t *testing.T) {
+	const (
+		workers = 8
+		updates = 100
+	)
+
+	s := NewStore(New[int]())
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range updates {
+				// Each worker increments the shared counter and its own one, retrying on conflicts.
+				for {
+					txn := s.Begin()
+					counter := 0
+					if v := txn.Get([]byte("counter")); v != nil {
+						counter = *v
+					}
+					txn.Insert([]byte("counter"), lo.ToPtr(counter+1))
+					txn.Insert([]byte(fmt.Sprintf("worker/%d", w)), lo.ToPtr(i+1))
+
+					_, err := txn.Commit()
+					if err == nil {
+						break
+					}
+					if !assert.ErrorIs(t, err, ErrConflict) {
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	root := s.Root()
+	require.Equal(t, workers*updates, *root.Get([]byte("counter")))
+	for w := range workers {
+		require.Equal(t, updates, *root.Get([]byte(fmt.Sprintf("worker/%d", w))))
+	}
+}
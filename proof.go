@@ -0,0 +1,225 @@
+package iradix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidProof is returned if proof doesn't prove the claim.
+var ErrInvalidProof = errors.New("invalid proof")
+
+// ProofEdge is the edge of the node included in the proof.
+type ProofEdge struct {
+	Label byte
+	Hash  Hash
+}
+
+// ProofNode is the node on the path from the root to the key.
+type ProofNode struct {
+	Prefix []byte
+
+	// ValueHash is the hash of the value stored in the node, nil if there is no value.
+	ValueHash *Hash
+
+	Edges []ProofEdge
+}
+
+// Proof proves that key maps to the value, or that the key is absent, in the tree with the given
+// root hash. It contains the nodes on the path from the root to the key.
+type Proof struct {
+	Nodes []ProofNode
+}
+
+// Prove returns the proof of the value stored under the key, or of the key absence.
+func (n *Node[T]) Prove(k []byte, hasher ValueHasher[T]) Proof {
+	var proof Proof
+	search := k
+	for {
+		es := n.children()
+		pn := ProofNode{
+			Prefix:    n.prefix,
+			ValueHash: n.valueHash(hasher),
+			Edges:     make([]ProofEdge, 0, es.len()),
+		}
+		for label, child := range es.all() {
+			pn.Edges = append(pn.Edges, ProofEdge{Label: label, Hash: child.Hash(hasher)})
+		}
+		proof.Nodes = append(proof.Nodes, pn)
+
+		if !bytes.HasPrefix(search, n.prefix) {
+			return proof
+		}
+		search = search[len(n.prefix):]
+		if len(search) == 0 {
+			return proof
+		}
+		if _, n = n.getEdge(search[0]); n == nil {
+			return proof
+		}
+	}
+}
+
+// VerifyProof verifies that in the tree with the root hash, key maps to the value or, if value
+// is nil, that key is absent.
+func VerifyProof[T any](rootHash Hash, k []byte, value *T, proof Proof, hasher ValueHasher[T]) error {
+	if len(proof.Nodes) == 0 {
+		return fmt.Errorf("empty proof: %w", ErrInvalidProof)
+	}
+
+	// Verify that the path leads to the key.
+	var found *Hash
+	search := k
+	last := len(proof.Nodes) - 1
+	for i, pn := range proof.Nodes {
+		if i > 0 && len(pn.Prefix) == 0 {
+			return fmt.Errorf("empty prefix: %w", ErrInvalidProof)
+		}
+		// Node is reached through the edge labelled by the first byte of its prefix, which must be the next
+		// byte of the key, even if the rest of the prefix diverges from it.
+		if i > 0 && pn.Prefix[0] != search[0] {
+			return fmt.Errorf("path doesn't follow the key: %w", ErrInvalidProof)
+		}
+		if !bytes.HasPrefix(search, pn.Prefix) {
+			if i != last {
+				return fmt.Errorf("path diverges from the key: %w", ErrInvalidProof)
+			}
+			break
+		}
+		search = search[len(pn.Prefix):]
+		if len(search) == 0 {
+			if i != last {
+				return fmt.Errorf("path is longer than the key: %w", ErrInvalidProof)
+			}
+			found = pn.ValueHash
+			break
+		}
+		_, exists := proofEdge(pn, search[0])
+		if exists != (i != last) {
+			return fmt.Errorf("path doesn't follow the key: %w", ErrInvalidProof)
+		}
+	}
+
+	switch {
+	case value == nil && found != nil:
+		return fmt.Errorf("key exists: %w", ErrInvalidProof)
+	case value != nil && found == nil:
+		return fmt.Errorf("key does not exist: %w", ErrInvalidProof)
+	case value != nil && *found != Hash(sha256.Sum256(hasher(nil, value))):
+		return fmt.Errorf("value mismatch: %w", ErrInvalidProof)
+	}
+
+	// Verify that the path belongs to the tree.
+	h := hashProofNode(proof.Nodes[last])
+	for i := last - 1; i >= 0; i-- {
+		e, exists := proofEdge(proof.Nodes[i], proof.Nodes[i+1].Prefix[0])
+		if !exists || e.Hash != h {
+			return fmt.Errorf("hash mismatch: %w", ErrInvalidProof)
+		}
+		h = hashProofNode(proof.Nodes[i])
+	}
+	if h != rootHash {
+		return fmt.Errorf("root hash mismatch: %w", ErrInvalidProof)
+	}
+	return nil
+}
+
+// MarshalBinary encodes the proof.
+func (p Proof) MarshalBinary() ([]byte, error) {
+	b := binary.AppendUvarint(nil, uint64(len(p.Nodes)))
+	for _, pn := range p.Nodes {
+		b = binary.AppendUvarint(b, uint64(len(pn.Prefix)))
+		b = append(b, pn.Prefix...)
+		if pn.ValueHash == nil {
+			b = append(b, 0)
+		} else {
+			b = append(b, 1)
+			b = append(b, pn.ValueHash[:]...)
+		}
+		b = binary.AppendUvarint(b, uint64(len(pn.Edges)))
+		for _, e := range pn.Edges {
+			b = append(b, e.Label)
+			b = append(b, e.Hash[:]...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes the proof encoded by MarshalBinary.
+func (p *Proof) UnmarshalBinary(data []byte) error {
+	d := byteDecoder{data: data}
+	count := d.uvarint(len(data))
+	p.Nodes = make([]ProofNode, 0, count)
+	for range count {
+		var pn ProofNode
+		pn.Prefix = copyPrefix(d.bytes(int(d.uvarint(len(d.data)))))
+		if flag := d.bytes(1); len(flag) == 1 && flag[0] == 1 {
+			pn.ValueHash = &Hash{}
+			copy(pn.ValueHash[:], d.bytes(sha256.Size))
+		}
+		numEdges := d.uvarint(256)
+		pn.Edges = make([]ProofEdge, numEdges)
+		for i := range pn.Edges {
+			pn.Edges[i].Label = d.bytes(1)[0]
+			copy(pn.Edges[i].Hash[:], d.bytes(sha256.Size))
+		}
+		if d.failed {
+			return fmt.Errorf("truncated proof: %w", ErrInvalidProof)
+		}
+		p.Nodes = append(p.Nodes, pn)
+	}
+	switch {
+	case d.failed:
+		return fmt.Errorf("truncated proof: %w", ErrInvalidProof)
+	case len(d.data) > 0:
+		return fmt.Errorf("unexpected trailing data: %w", ErrInvalidProof)
+	}
+	return nil
+}
+
+func proofEdge(pn ProofNode, label byte) (ProofEdge, bool) {
+	for _, e := range pn.Edges {
+		if e.Label == label {
+			return e, true
+		}
+	}
+	return ProofEdge{}, false
+}
+
+func hashProofNode(pn ProofNode) Hash {
+	labels := make([]byte, 0, len(pn.Edges))
+	childHashes := make([]Hash, 0, len(pn.Edges))
+	for _, e := range pn.Edges {
+		labels = append(labels, e.Label)
+		childHashes = append(childHashes, e.Hash)
+	}
+	return hashNode(pn.Prefix, pn.ValueHash, labels, childHashes)
+}
+
+// byteDecoder decodes binary messages. After the first failure, it returns zeroed data.
+type byteDecoder struct {
+	data   []byte
+	failed bool
+}
+
+func (d *byteDecoder) uvarint(limit int) uint64 {
+	v, n := binary.Uvarint(d.data)
+	if d.failed || n <= 0 || v > uint64(limit) {
+		d.failed = true
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *byteDecoder) bytes(n int) []byte {
+	if d.failed || len(d.data) < n {
+		d.failed = true
+		return make([]byte, n)
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b
+}
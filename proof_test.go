@@ -0,0 +1,62 @@
+package iradix
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProof(t *testing.T) {
+	hasher := intCodec{}.Encode
+	keys := []string{"", "a", "ab", "abc", "abd", "b", "foo/bar", "foo/baz"}
+	r := buildTree(keys...)
+	rootHash := r.Hash(hasher)
+
+	verify := func(k string, v *int, p Proof) error {
+		// Proof is transferred in binary form.
+		data, err := p.MarshalBinary()
+		require.NoError(t, err)
+		var p2 Proof
+		require.NoError(t, p2.UnmarshalBinary(data))
+		return VerifyProof(rootHash, []byte(k), v, p2, hasher)
+	}
+
+	for i, k := range keys {
+		p := r.Prove([]byte(k), hasher)
+		require.NoError(t, verify(k, lo.ToPtr(i), p), k)
+		require.ErrorIs(t, verify(k, lo.ToPtr(-1), p), ErrInvalidProof, k)
+		require.ErrorIs(t, verify(k, nil, p), ErrInvalidProof, k)
+		require.ErrorIs(t, VerifyProof(Hash{}, []byte(k), lo.ToPtr(i), p, hasher), ErrInvalidProof, k)
+	}
+
+	for _, k := range []string{"c", "abe", "abcd", "fo", "foo/", "foo/bax", "foo/barr", "fox"} {
+		p := r.Prove([]byte(k), hasher)
+		require.NoError(t, verify(k, nil, p), k)
+		require.ErrorIs(t, verify(k, lo.ToPtr(0), p), ErrInvalidProof, k)
+	}
+
+	// Proof of one key can't be used for another one.
+	p := r.Prove([]byte("abc"), hasher)
+	require.ErrorIs(t, verify("abd", lo.ToPtr(3), p), ErrInvalidProof)
+	require.ErrorIs(t, verify("ab", nil, p), ErrInvalidProof)
+	// But it proves absence of the keys it leads to.
+	require.NoError(t, verify("abcd", nil, p))
+
+	// Proof of absence leading to another child of the node can't prove absence of the existing key.
+	r2 := buildTree("b", "c")
+	p2 := r2.Prove([]byte("c"), hasher)
+	require.NoError(t, VerifyProof(r2.Hash(hasher), []byte("c"), lo.ToPtr(1), p2, hasher))
+	require.ErrorIs(t, VerifyProof(r2.Hash(hasher), []byte("b"), nil, p2, hasher), ErrInvalidProof)
+
+	// Tampered proof is rejected.
+	p.Nodes[len(p.Nodes)-1].Prefix = []byte("cd")
+	require.ErrorIs(t, verify("abcd", lo.ToPtr(3), p), ErrInvalidProof)
+
+	data, err := r.Prove([]byte("abc"), hasher).MarshalBinary()
+	require.NoError(t, err)
+	for i := range data {
+		var p Proof
+		require.ErrorIs(t, p.UnmarshalBinary(data[:i]), ErrInvalidProof)
+	}
+}
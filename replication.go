@@ -0,0 +1,266 @@
+package iradix
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// Replication stream is a sequence of frames:
+//   - type    byte
+//   - length  uint32 (length of the payload)
+//   - crc32   uint32 (Castagnoli, computed over the payload)
+//   - payload
+//
+// Follower starts by sending msgHello containing its revision and the hash of its root. If leader retains
+// that revision in its history and the hash of its root matches, it streams msgChanges frames containing
+// the changes committed after it. Otherwise, msgSnapshot frame containing the full snapshot of the tree
+// is sent first. Hashes are computed using Encode method of the codec.
+//
+// msgChanges payload contains previous revision, new revision and the operations encoded the same
+// way as in the write-ahead log.
+//
+// All the integers are little endian.
+
+const (
+	frameHeaderSize      = 9
+	maxFrameSize         = 1 << 30
+	helloSize            = 8 + sha256.Size
+	msgHello        byte = 1
+	msgSnapshot     byte = 2
+	msgChanges      byte = 3
+)
+
+var (
+	// ErrReplicationGap is returned by follower receiving changes not following its revision.
+	ErrReplicationGap = errors.New("replication gap detected")
+
+	// ErrProtocol is returned on unexpected message.
+	ErrProtocol = errors.New("replication protocol violation")
+)
+
+// Leader streams the changes committed to the store to followers.
+type Leader[T any] struct {
+	store *Store[T]
+	codec ValueCodec[T]
+}
+
+// NewLeader creates new replication leader. To let reconnecting followers resume streaming
+// without full snapshot transfer, store should track the history. Roots are hashed using Encode method
+// of the codec, and hashes are cached in the nodes, so trees of the store must not be hashed with
+// another hasher.
+func NewLeader[T any](store *Store[T], codec ValueCodec[T]) *Leader[T] {
+	return &Leader[T]{
+		store: store,
+		codec: codec,
+	}
+}
+
+// Serve streams the changes to the follower connected by conn until context is canceled
+// or connection fails.
+func (l *Leader[T]) Serve(ctx context.Context, conn io.ReadWriter) error {
+	msgType, payload, err := readFrame(conn, nil)
+	if err != nil {
+		return err
+	}
+	if msgType != msgHello || len(payload) != helloSize {
+		return fmt.Errorf("expected hello message: %w", ErrProtocol)
+	}
+	revision := binary.LittleEndian.Uint64(payload)
+	hash := Hash(payload[8:])
+
+	sub, err := l.store.SubscribeFrom(nil, revision, SubscriptionConfig{})
+	if err == nil {
+		// History might contain older version only, meaning that leader never had the follower's revision,
+		// or follower's tree might have diverged from the leader's one at the same revision.
+		if from := sub.root(); from.revision != revision || from.Hash(l.codec.Encode) != hash {
+			sub.Close()
+			err = ErrRevisionUnavailable
+		}
+	}
+	switch {
+	case errors.Is(err, ErrRevisionUnavailable):
+		sub = l.store.Subscribe(nil, SubscriptionConfig{})
+		root := sub.root()
+
+		buf := &bytes.Buffer{}
+		if err := WriteSnapshot(buf, root, l.codec); err != nil {
+			sub.Close()
+			return err
+		}
+		if err := writeFrame(conn, msgSnapshot, buf.Bytes()); err != nil {
+			sub.Close()
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	defer sub.Close()
+
+	ops := opEncoder[T]{codec: l.codec}
+	var b []byte
+	for {
+		batch, err := sub.Next(ctx)
+		if err != nil {
+			return err
+		}
+
+		b = binary.LittleEndian.AppendUint64(b[:0], batch.PrevRevision)
+		b = binary.LittleEndian.AppendUint64(b, batch.Revision)
+		for _, c := range batch.Changes {
+			b = ops.append(b, c.Key, c.New)
+		}
+		if err := writeFrame(conn, msgChanges, b); err != nil {
+			return err
+		}
+	}
+}
+
+// Follower applies the changes streamed by the leader to its store.
+type Follower[T any] struct {
+	store *Store[T]
+	codec ValueCodec[T]
+}
+
+// NewFollower creates new replication follower. Like in the leader, roots are hashed using Encode
+// method of the codec.
+func NewFollower[T any](store *Store[T], codec ValueCodec[T]) *Follower[T] {
+	return &Follower[T]{
+		store: store,
+		codec: codec,
+	}
+}
+
+// Run receives the changes from the leader connected by conn and applies them to the store.
+// It returns nil when leader closes the stream. On ErrReplicationGap, follower should reconnect,
+// so leader sends it the snapshot.
+func (f *Follower[T]) Run(conn io.ReadWriter) error {
+	root := f.store.Root()
+	hash := root.Hash(f.codec.Encode)
+	hello := binary.LittleEndian.AppendUint64(nil, root.revision)
+	hello = append(hello, hash[:]...)
+	if err := writeFrame(conn, msgHello, hello); err != nil {
+		return err
+	}
+
+	var buf []byte
+	for {
+		msgType, payload, err := readFrame(conn, buf)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf = payload
+
+		switch msgType {
+		case msgSnapshot:
+			root, err := ReadSnapshot(bytes.NewReader(payload), f.codec)
+			if err != nil {
+				return err
+			}
+			if err := f.store.replaceRoot(root); err != nil {
+				return err
+			}
+		case msgChanges:
+			if len(payload) < 16 {
+				return fmt.Errorf("invalid changes message: %w", ErrProtocol)
+			}
+			prevRevision := binary.LittleEndian.Uint64(payload)
+			revision := binary.LittleEndian.Uint64(payload[8:])
+			if err := f.store.applyReplicated(prevRevision, revision, payload[16:], f.codec); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected message %d: %w", msgType, ErrProtocol)
+		}
+	}
+}
+
+// replaceRoot commits the root received from the leader.
+func (s *Store[T]) replaceRoot(root *Node[T]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	_, err := s.commit(&Txn[T]{
+		revision: root.revision,
+		base:     s.root.Load(),
+		root:     root,
+	})
+	return err
+}
+
+// applyReplicated applies the operations received from the leader. New root gets the leader's
+// revision, so the following changes can be verified.
+func (s *Store[T]) applyReplicated(prevRevision, revision uint64, ops []byte, codec ValueCodec[T]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	root := s.root.Load()
+	if root.revision != prevRevision || revision <= prevRevision {
+		return fmt.Errorf("received changes from revision %d to %d, local revision is %d: %w",
+			prevRevision, revision, root.revision, ErrReplicationGap)
+	}
+
+	txn := NewTxn(root)
+	txn.revision = revision
+	if err := applyOps(txn, codec, ops); err != nil {
+		return err
+	}
+	_, err := s.commit(txn)
+	return err
+}
+
+func writeFrame(w io.Writer, msgType byte, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes exceeds the limit: %w", len(payload), ErrProtocol)
+	}
+	var header [frameHeaderSize]byte
+	header[0] = msgType
+	binary.LittleEndian.PutUint32(header[1:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[5:], crc32.Checksum(payload, crcTable))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	// Empty payload is not written, as zero-length writes block on synchronous connections like net.Pipe
+	// until the other side reads.
+	if len(payload) == 0 {
+		return nil
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(r io.Reader, buf []byte) (byte, []byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes exceeds the limit: %w", size, ErrProtocol)
+	}
+	if uint32(cap(buf)) < size {
+		buf = make([]byte, size)
+	}
+	buf = buf[:size]
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+	if crc32.Checksum(buf, crcTable) != binary.LittleEndian.Uint32(header[5:]) {
+		return 0, nil, fmt.Errorf("frame checksum mismatch: %w", ErrCorrupted)
+	}
+	return header[0], buf, nil
+}
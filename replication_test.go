@@ -0,0 +1,157 @@
+package iradix
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type replica struct {
+	store  *Store[int]
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startReplica(t *testing.T, leader *Leader[int], store *Store[int]) *replica {
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderConn, followerConn := net.Pipe()
+	r := &replica{
+		store:  store,
+		cancel: cancel,
+		done:   make(chan struct{}, 2),
+	}
+	go func() {
+		defer func() { r.done <- struct{}{} }()
+		assert.ErrorIs(t, leader.Serve(ctx, leaderConn), context.Canceled)
+		assert.NoError(t, leaderConn.Close())
+	}()
+	go func() {
+		defer func() { r.done <- struct{}{} }()
+		assert.NoError(t, NewFollower(store, intCodec{}).Run(followerConn))
+	}()
+	return r
+}
+
+func (r *replica) stop() {
+	r.cancel()
+	<-r.done
+	<-r.done
+}
+
+func requireReplicated(t *testing.T, leader, follower *Store[int]) {
+	require.Eventually(t, func() bool {
+		return follower.Root().revision == leader.Root().revision &&
+			assert.ObjectsAreEqual(collect(t, leader.Root()), collect(t, follower.Root()))
+	}, time.Second, time.Millisecond)
+}
+
+func TestReplication(t *testing.T) {
+	leaderStore := NewStore(New[int]())
+	require.NoError(t, leaderStore.TrackHistory(NewHistory[int](HistoryConfig{MaxVersions: 10})))
+	insertInStore(t, leaderStore, "a", "b")
+	leader := NewLeader(leaderStore, intCodec{})
+
+	followerStore := NewStore(New[int]())
+	r := startReplica(t, leader, followerStore)
+
+	// Follower starts with revision 0 which is no longer retained by leader, so snapshot is sent.
+	requireReplicated(t, leaderStore, followerStore)
+
+	for i := range 20 {
+		require.NoError(t, leaderStore.Update(func(txn *Txn[int]) error {
+			txn.Insert([]byte(fmt.Sprintf("key/%d", i)), lo.ToPtr(i))
+			txn.Delete([]byte(fmt.Sprintf("key/%d", i-5)))
+			return nil
+		}))
+	}
+	requireReplicated(t, leaderStore, followerStore)
+	r.stop()
+
+	// Follower resumes from its revision.
+	insertInStore(t, leaderStore, "c")
+	_, unchanged := followerStore.Root().getEdge('a')
+	r = startReplica(t, leader, followerStore)
+	requireReplicated(t, leaderStore, followerStore)
+	_, node := followerStore.Root().getEdge('a')
+	require.Same(t, unchanged, node)
+	r.stop()
+}
+
+func TestReplicationFollowerAhead(t *testing.T) {
+	leaderStore := NewStore(New[int]())
+	require.NoError(t, leaderStore.TrackHistory(NewHistory[int](HistoryConfig{})))
+	insertInStore(t, leaderStore, "a")
+	insertInStore(t, leaderStore, "b")
+	leader := NewLeader(leaderStore, intCodec{})
+
+	// Follower has revision never seen by the leader, so snapshot is sent.
+	followerStore := NewStore(New[int]())
+	insertInStore(t, followerStore, "x")
+	insertInStore(t, followerStore, "y")
+	insertInStore(t, followerStore, "z")
+
+	r := startReplica(t, leader, followerStore)
+	requireReplicated(t, leaderStore, followerStore)
+	insertInStore(t, leaderStore, "c")
+	requireReplicated(t, leaderStore, followerStore)
+	r.stop()
+}
+
+func TestReplicationDivergedFollower(t *testing.T) {
+	leaderStore := NewStore(New[int]())
+	require.NoError(t, leaderStore.TrackHistory(NewHistory[int](HistoryConfig{})))
+	insertInStore(t, leaderStore, "a")
+	insertInStore(t, leaderStore, "b")
+	leader := NewLeader(leaderStore, intCodec{})
+
+	// Follower has the revision retained by the leader, but different content, so snapshot is sent.
+	followerStore := NewStore(New[int]())
+	insertInStore(t, followerStore, "x")
+	insertInStore(t, followerStore, "y")
+	require.Equal(t, leaderStore.Root().revision, followerStore.Root().revision)
+
+	r := startReplica(t, leader, followerStore)
+	requireReplicated(t, leaderStore, followerStore)
+	insertInStore(t, leaderStore, "c")
+	requireReplicated(t, leaderStore, followerStore)
+	r.stop()
+}
+
+func TestReplicationFrameTooLarge(t *testing.T) {
+	header := []byte{msgSnapshot, 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0}
+	_, _, err := readFrame(bytes.NewReader(header), nil)
+	require.ErrorIs(t, err, ErrProtocol)
+}
+
+func TestReplicationEmptyFrame(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	defer func() {
+		require.NoError(t, clientConn.Close())
+		<-done
+	}()
+
+	go func() {
+		defer close(done)
+		assert.NoError(t, writeFrame(serverConn, msgSyncFetch, nil))
+	}()
+	msgType, payload, err := readFrame(clientConn, nil)
+	require.NoError(t, err)
+	require.Equal(t, msgSyncFetch, msgType)
+	require.Empty(t, payload)
+}
+
+func TestReplicationGap(t *testing.T) {
+	s := NewStore(New[int]())
+	insertInStore(t, s, "a")
+
+	require.ErrorIs(t, s.applyReplicated(2, 3, nil, intCodec{}), ErrReplicationGap)
+	require.ErrorIs(t, s.applyReplicated(1, 1, nil, intCodec{}), ErrReplicationGap)
+}
@@ -0,0 +1,77 @@
+package iradix
+
+// NewSet returns an empty set.
+func NewSet() *Set {
+	return SetFromNode(New[struct{}]())
+}
+
+// SetFromNode returns the set of keys stored under root.
+func SetFromNode(root *Node[struct{}]) *Set {
+	return &Set{root: root}
+}
+
+// Set is the immutable set of byte keys built on the radix tree. Nodes store values of type struct{},
+// which take no space, so presence of the key costs 8 bytes per node: the value identifier which marks
+// the nodes storing keys.
+type Set struct {
+	root *Node[struct{}]
+}
+
+// Root returns the underlying root of the set.
+func (s *Set) Root() *Node[struct{}] {
+	return s.root
+}
+
+// Contains returns true if key is in the set.
+func (s *Set) Contains(k []byte) bool {
+	return s.root.Get(k) != nil
+}
+
+// Txn creates new transaction that can be used to mutate the set.
+func (s *Set) Txn() *SetTxn {
+	return &SetTxn{txn: NewTxn(s.root)}
+}
+
+// Walk calls fn for each key in the set, in order. Key passed to fn is valid only until fn returns.
+// Iteration stops on the first error returned by fn, and that error is returned.
+func (s *Set) Walk(fn func(k []byte) error) error {
+	return s.WalkRange(nil, nil, fn)
+}
+
+// WalkPrefix works like Walk but visits only the keys having the prefix.
+func (s *Set) WalkPrefix(prefix []byte, fn func(k []byte) error) error {
+	return s.WalkRange(prefix, prefixEnd(prefix), fn)
+}
+
+// WalkRange works like Walk but visits only the keys in range [from, to). Nil to means there is no upper bound.
+func (s *Set) WalkRange(from, to []byte, fn func(k []byte) error) error {
+	return s.root.walk(from, to, func(k []byte, _ *struct{}) error {
+		return fn(k)
+	})
+}
+
+// SetTxn is a transaction on the set. Like Txn, it is not thread safe.
+type SetTxn struct {
+	txn *Txn[struct{}]
+}
+
+// Contains returns true if key is in the set within the transaction.
+func (t *SetTxn) Contains(k []byte) bool {
+	return t.txn.Get(k) != nil
+}
+
+// Add adds the key to the set, returning true if it has not been there before.
+func (t *SetTxn) Add(k []byte) bool {
+	_, exists := t.txn.Set(k, struct{}{})
+	return !exists
+}
+
+// Remove removes the key from the set, returning true if it has been there.
+func (t *SetTxn) Remove(k []byte) bool {
+	return t.txn.Delete(k) != nil
+}
+
+// Commit returns the set with the changes applied.
+func (t *SetTxn) Commit() *Set {
+	return SetFromNode(t.txn.Commit())
+}
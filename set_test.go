@@ -0,0 +1,66 @@
+package iradix
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+func setKeys(t *testing.T, walk func(fn func(k []byte) error) error) []string {
+	var keys []string
+	require.NoError(t, walk(func(k []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	}))
+	return keys
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet()
+	txn := s.Txn()
+	for _, k := range []string{"foo", "foobar", "", "bar", "fo", "zip", "foo\xff", "fop"} {
+		require.True(t, txn.Add([]byte(k)))
+	}
+	require.False(t, txn.Add([]byte("foo")))
+	require.True(t, txn.Contains([]byte("foo")))
+	require.True(t, txn.Remove([]byte("zip")))
+	require.False(t, txn.Remove([]byte("zip")))
+	require.False(t, txn.Remove([]byte("f")))
+	s2 := txn.Commit()
+
+	require.False(t, s.Contains([]byte("foo")))
+	require.True(t, s2.Contains([]byte("foo")))
+	require.True(t, s2.Contains([]byte("")))
+	require.False(t, s2.Contains([]byte("f")))
+	require.False(t, s2.Contains([]byte("zip")))
+
+	require.Equal(t, []string{"", "bar", "fo", "foo", "foobar", "foo\xff", "fop"}, setKeys(t, s2.Walk))
+	require.Equal(t, []string{"foo", "foobar", "foo\xff"}, setKeys(t, func(fn func(k []byte) error) error {
+		return s2.WalkPrefix([]byte("foo"), fn)
+	}))
+	require.Equal(t, []string{"bar", "fo", "foo"}, setKeys(t, func(fn func(k []byte) error) error {
+		return s2.WalkRange([]byte("a"), []byte("foo\x00"), fn)
+	}))
+	require.Empty(t, setKeys(t, s.Walk))
+
+	errStop := errors.New("stop")
+	require.ErrorIs(t, s2.Walk(func(k []byte) error { return errStop }), errStop)
+}
+
+func TestSetAddDoesNotAllocateValues(t *testing.T) {
+	txn := NewSet().Txn()
+	txn.Add([]byte("a"))
+	txn.Add([]byte("b"))
+
+	// Updating the existing key within the transaction touches only nodes created by the transaction.
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		txn.Add([]byte("a"))
+	}))
+}
+
+func TestSetNodeSize(t *testing.T) {
+	// Set pays only for the value identifier marking the presence of the key.
+	require.Equal(t, unsafe.Sizeof(Node[int]{})-unsafe.Sizeof(0), unsafe.Sizeof(Node[struct{}]{}))
+}
@@ -0,0 +1,327 @@
+package iradix
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+// Snapshot is a sequence of records preceded by the header and followed by the trailer.
+// Records are stored in post-order, so children are always stored before their parent.
+//
+// Header:
+//   - magic    [4]byte
+//   - version  uint32
+//   - revision uint64 (used by deltas only, zero in snapshots)
+//   - baseHash [32]byte (deltas only, hash of the base root)
+//
+// Node record:
+//   - kind      byte (recordNode)
+//   - revision  uint64
+//   - flags     byte
+//   - edges     uint16
+//   - prefixLen uint32
+//   - valueLen  uint32
+//   - prefix    [prefixLen]byte
+//   - value     [valueLen]byte
+//   - labels    [edges]byte
+//   - children  [edges]uint64 (offsets of the child records)
+//
+// Base reference record (deltas only):
+//   - kind    byte (recordBaseRef)
+//   - pathLen uint32
+//   - path    [pathLen]byte
+//
+// Trailer:
+//   - root  uint64 (offset of the root record)
+//   - crc32 uint32 (Castagnoli, computed over everything preceding it)
+//
+// All the integers are little endian.
+
+const (
+	snapshotVersion        = 1
+	headerSize             = 16
+	trailerSize            = 12
+	nodeRecordSize         = 20
+	baseRefRecordSize      = 5
+	recordNode             = 0
+	recordBaseRef          = 1
+	flagValue         byte = 1
+)
+
+var (
+	snapshotMagic = [4]byte{'I', 'R', 'X', 'S'}
+	crcTable      = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// ErrCorrupted is returned if snapshot can't be decoded.
+var ErrCorrupted = errors.New("snapshot is corrupted")
+
+// WriteSnapshot writes the full structure of the tree to w.
+func WriteSnapshot[T any](w io.Writer, root *Node[T], codec ValueCodec[T]) error {
+	e := newEncoder(w, codec)
+	if err := e.writeHeader(snapshotMagic, 0); err != nil {
+		return err
+	}
+	rootOffset, err := e.encodeTree(root)
+	if err != nil {
+		return err
+	}
+	return e.writeTrailer(rootOffset)
+}
+
+// ReadSnapshot reads the tree written by WriteSnapshot.
+func ReadSnapshot[T any](r io.Reader, codec ValueCodec[T]) (*Node[T], error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	_, rootOffset, err := openSnapshot(data, snapshotMagic)
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder[T]{
+		data:  data,
+		codec: codec,
+	}
+	return d.decode(rootOffset, uint64(len(data)))
+}
+
+type encoder[T any] struct {
+	w      *bufio.Writer
+	crc    hash.Hash32
+	codec  ValueCodec[T]
+	offset uint64
+	buf    []byte
+}
+
+func newEncoder[T any](w io.Writer, codec ValueCodec[T]) *encoder[T] {
+	return &encoder[T]{
+		w:     bufio.NewWriter(w),
+		crc:   crc32.New(crcTable),
+		codec: codec,
+	}
+}
+
+func (e *encoder[T]) write(b []byte) error {
+	e.offset += uint64(len(b))
+	_, _ = e.crc.Write(b)
+	_, err := e.w.Write(b)
+	return err
+}
+
+func (e *encoder[T]) writeHeader(magic [4]byte, revision uint64) error {
+	b := append(e.buf[:0], magic[:]...)
+	b = binary.LittleEndian.AppendUint32(b, snapshotVersion)
+	b = binary.LittleEndian.AppendUint64(b, revision)
+	e.buf = b
+	return e.write(b)
+}
+
+func (e *encoder[T]) writeTrailer(rootOffset uint64) error {
+	b := binary.LittleEndian.AppendUint64(e.buf[:0], rootOffset)
+	e.buf = b
+	if err := e.write(b); err != nil {
+		return err
+	}
+	b = binary.LittleEndian.AppendUint32(e.buf[:0], e.crc.Sum32())
+	if _, err := e.w.Write(b); err != nil {
+		return err
+	}
+	return e.w.Flush()
+}
+
+func (e *encoder[T]) encodeTree(n *Node[T]) (uint64, error) {
+	children := make([]uint64, 0, n.children().len())
+	for _, child := range n.children().all() {
+		offset, err := e.encodeTree(child)
+		if err != nil {
+			return 0, err
+		}
+		children = append(children, offset)
+	}
+	return e.writeNode(n, children)
+}
+
+func (e *encoder[T]) writeNode(n *Node[T], children []uint64) (uint64, error) {
+	offset := e.offset
+
+	b := append(e.buf[:0], make([]byte, nodeRecordSize)...)
+	b = append(b, n.prefix...)
+	var flags byte
+	valueLen := 0
+	if n.hasValue() {
+		flags |= flagValue
+		b = e.codec.Encode(b, &n.value)
+		valueLen = len(b) - nodeRecordSize - len(n.prefix)
+	}
+	es := n.children()
+	for label := range es.all() {
+		b = append(b, label)
+	}
+	for _, child := range children {
+		b = binary.LittleEndian.AppendUint64(b, child)
+	}
+
+	b[0] = recordNode
+	binary.LittleEndian.PutUint64(b[1:], n.revision)
+	b[9] = flags
+	binary.LittleEndian.PutUint16(b[10:], uint16(es.len()))
+	binary.LittleEndian.PutUint32(b[12:], uint32(len(n.prefix)))
+	binary.LittleEndian.PutUint32(b[16:], uint32(valueLen))
+	e.buf = b
+
+	return offset, e.write(b)
+}
+
+func (e *encoder[T]) writeBaseRef(path []byte) (uint64, error) {
+	offset := e.offset
+
+	b := append(e.buf[:0], recordBaseRef)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(path)))
+	b = append(b, path...)
+	e.buf = b
+
+	return offset, e.write(b)
+}
+
+// nodeRecord is the parsed node record referencing the underlying snapshot bytes.
+type nodeRecord struct {
+	size     uint64
+	revision uint64
+	hasValue bool
+	prefix   []byte
+	value    []byte
+	labels   []byte
+	children []byte
+}
+
+func (r nodeRecord) child(i int) uint64 {
+	return binary.LittleEndian.Uint64(r.children[8*i:])
+}
+
+// parseNodeRecord parses node record stored at offset. The record must end before limit.
+func parseNodeRecord(data []byte, offset, limit uint64) (nodeRecord, error) {
+	if offset >= limit || limit-offset < nodeRecordSize || data[offset] != recordNode {
+		return nodeRecord{}, ErrCorrupted
+	}
+	h := data[offset : offset+nodeRecordSize]
+	numEdges := uint64(binary.LittleEndian.Uint16(h[10:]))
+	prefixLen := uint64(binary.LittleEndian.Uint32(h[12:]))
+	valueLen := uint64(binary.LittleEndian.Uint32(h[16:]))
+
+	start := offset + nodeRecordSize
+	end := start + prefixLen + valueLen + 9*numEdges
+	if end > limit {
+		return nodeRecord{}, ErrCorrupted
+	}
+
+	r := nodeRecord{
+		size:     end - offset,
+		revision: binary.LittleEndian.Uint64(h[1:]),
+		hasValue: h[9]&flagValue != 0,
+	}
+	r.prefix, start = data[start:start+prefixLen:start+prefixLen], start+prefixLen
+	r.value, start = data[start:start+valueLen:start+valueLen], start+valueLen
+	r.labels, start = data[start:start+numEdges:start+numEdges], start+numEdges
+	r.children = data[start:end:end]
+	return r, nil
+}
+
+// parseBaseRefRecord returns the path stored in the base reference record.
+func parseBaseRefRecord(data []byte, offset, limit uint64) ([]byte, error) {
+	if offset >= limit || limit-offset < baseRefRecordSize || data[offset] != recordBaseRef {
+		return nil, ErrCorrupted
+	}
+	start := offset + baseRefRecordSize
+	end := start + uint64(binary.LittleEndian.Uint32(data[offset+1:]))
+	if end > limit {
+		return nil, ErrCorrupted
+	}
+	return data[start:end:end], nil
+}
+
+// openSnapshot verifies the snapshot and returns the revision stored in the header
+// and the offset of the root record.
+func openSnapshot(data []byte, magic [4]byte) (uint64, uint64, error) {
+	if len(data) < headerSize+trailerSize {
+		return 0, 0, fmt.Errorf("snapshot is too short: %w", ErrCorrupted)
+	}
+	if !bytes.Equal(data[:4], magic[:]) {
+		return 0, 0, fmt.Errorf("invalid magic number: %w", ErrCorrupted)
+	}
+	if v := binary.LittleEndian.Uint32(data[4:]); v != snapshotVersion {
+		return 0, 0, fmt.Errorf("unsupported version %d: %w", v, ErrCorrupted)
+	}
+	crcOffset := len(data) - 4
+	if crc32.Checksum(data[:crcOffset], crcTable) != binary.LittleEndian.Uint32(data[crcOffset:]) {
+		return 0, 0, fmt.Errorf("checksum mismatch: %w", ErrCorrupted)
+	}
+
+	rootOffset := binary.LittleEndian.Uint64(data[len(data)-trailerSize:])
+	if rootOffset < headerSize {
+		return 0, 0, fmt.Errorf("invalid root offset: %w", ErrCorrupted)
+	}
+	return binary.LittleEndian.Uint64(data[8:]), rootOffset, nil
+}
+
+type decoder[T any] struct {
+	data  []byte
+	codec ValueCodec[T]
+	base  *Node[T]
+}
+
+// decode decodes the record stored at offset. Record must be stored before limit, which
+// guarantees that the process terminates even for malicious input.
+func (d *decoder[T]) decode(offset, limit uint64) (*Node[T], error) {
+	if offset < limit && d.data[offset] == recordBaseRef && d.base != nil {
+		path, err := parseBaseRefRecord(d.data, offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		n := d.base.nodeAt(path)
+		if n == nil {
+			return nil, fmt.Errorf("node %q does not exist in base: %w", path, ErrCorrupted)
+		}
+		return n, nil
+	}
+
+	r, err := parseNodeRecord(d.data, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &Node[T]{
+		revision: r.revision,
+	}
+	n.setPrefix(r.prefix)
+	if r.hasValue {
+		v, err := d.codec.Decode(r.value)
+		if err != nil {
+			return nil, err
+		}
+		n.setValue(v)
+	}
+	if len(r.labels) > 0 {
+		for i, label := range r.labels {
+			// Layouts count edges on add, so duplicates would make them inconsistent.
+			if i > 0 && label <= r.labels[i-1] {
+				return nil, fmt.Errorf("labels are not sorted: %w", ErrCorrupted)
+			}
+			child, err := d.decode(r.child(i), offset)
+			if err != nil {
+				return nil, err
+			}
+			if len(child.prefix) == 0 || child.prefix[0] != label {
+				return nil, fmt.Errorf("invalid edge label: %w", ErrCorrupted)
+			}
+			n.addEdge(edge[T]{label: label, node: child}, nil)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,151 @@
+package iradix
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	for _, r := range []*Node[int]{
+		New[int](),
+		buildTree(""),
+		buildTree("", "a", "ab", "abc", "b", "ba", "foo/bar", "foo/baz", "zip"),
+	} {
+		buf := &bytes.Buffer{}
+		require.NoError(t, WriteSnapshot[int](buf, r, intCodec{}))
+
+		r2, err := ReadSnapshot[int](bytes.NewReader(buf.Bytes()), intCodec{})
+		require.NoError(t, err)
+		require.Equal(t, CopyTreeValues(r), CopyTreeValues(r2))
+	}
+}
+
+func TestSnapshotCorrupted(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, WriteSnapshot[int](buf, buildTree("a", "ab", "b"), intCodec{}))
+	data := buf.Bytes()
+
+	for i := range data {
+		corrupted := bytes.Clone(data)
+		corrupted[i] ^= 0x01
+		_, err := ReadSnapshot[int](bytes.NewReader(corrupted), intCodec{})
+		require.ErrorIs(t, err, ErrCorrupted)
+	}
+
+	_, err := ReadSnapshot[int](bytes.NewReader(data[:len(data)-1]), intCodec{})
+	require.ErrorIs(t, err, ErrCorrupted)
+}
+
+func TestSnapshotInvalidLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(labels, children []byte)
+	}{
+		{
+			name: "unsorted",
+			modify: func(labels, children []byte) {
+				labels[0], labels[1] = labels[1], labels[0]
+				a, b := binary.LittleEndian.Uint64(children), binary.LittleEndian.Uint64(children[8:])
+				binary.LittleEndian.PutUint64(children, b)
+				binary.LittleEndian.PutUint64(children[8:], a)
+			},
+		},
+		{
+			name: "duplicated",
+			modify: func(labels, children []byte) {
+				labels[1] = labels[0]
+				copy(children[8:], children[:8])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, WriteSnapshot[int](buf, buildTree("a", "b"), intCodec{}))
+			data := buf.Bytes()
+			root := binary.LittleEndian.Uint64(data[len(data)-trailerSize:])
+
+			labels := data[root+nodeRecordSize:]
+			test.modify(labels, labels[2:])
+			binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.Checksum(data[:len(data)-4], crcTable))
+
+			_, err := ReadSnapshot[int](bytes.NewReader(data), intCodec{})
+			require.ErrorIs(t, err, ErrCorrupted)
+			require.ErrorContains(t, err, "labels are not sorted")
+		})
+	}
+}
+
+func TestDelta(t *testing.T) {
+	txn := NewTxn(New[int]())
+	for i := range 1000 {
+		txn.Insert([]byte(fmt.Sprintf("key/%04d", i)), lo.ToPtr(i))
+	}
+	base := txn.Commit()
+
+	full := &bytes.Buffer{}
+	require.NoError(t, WriteSnapshot[int](full, base, intCodec{}))
+	fullSize := full.Len()
+	base, err := ReadSnapshot[int](full, intCodec{})
+	require.NoError(t, err)
+
+	txn = NewTxn(base)
+	txn.Insert([]byte("key/0500"), lo.ToPtr(-1))
+	txn.Insert([]byte("key/1000"), lo.ToPtr(1000))
+	txn.Delete([]byte("key/0010"))
+	current := txn.Commit()
+
+	delta := &bytes.Buffer{}
+	require.NoError(t, WriteDelta[int](delta, base, current, intCodec{}))
+	require.Less(t, delta.Len(), fullSize/10)
+
+	restored, err := ApplyDelta[int](base, delta, intCodec{})
+	require.NoError(t, err)
+	require.Equal(t, collect(t, current), collect(t, restored))
+	require.Equal(t, current.revision, restored.revision)
+
+	// Unchanged subtrees are shared with base.
+	_, baseChild := base.nodeAt([]byte("key/0")).getEdge('9')
+	_, restoredChild := restored.nodeAt([]byte("key/0")).getEdge('9')
+	require.NotNil(t, baseChild)
+	require.Same(t, baseChild, restoredChild)
+
+	// Delta applies only to its base.
+	delta.Reset()
+	require.NoError(t, WriteDelta[int](delta, base, current, intCodec{}))
+	_, err = ApplyDelta[int](current, delta, intCodec{})
+	require.ErrorIs(t, err, ErrBaseMismatch)
+}
+
+func TestDeltaUnrelatedTrees(t *testing.T) {
+	base := buildTree("a", "b", "c")
+	current := buildTree("a", "b", "d")
+
+	delta := &bytes.Buffer{}
+	require.NoError(t, WriteDelta[int](delta, base, current, intCodec{}))
+	restored, err := ApplyDelta[int](base, delta, intCodec{})
+	require.NoError(t, err)
+	require.Equal(t, collect(t, current), collect(t, restored))
+}
+
+func TestDeltaAnotherBaseAtSameRevision(t *testing.T) {
+	base := buildTree("a", "b", "c")
+	other := buildTree("a", "b", "x")
+	require.Equal(t, base.revision, other.revision)
+
+	txn := NewTxn(base)
+	txn.Insert([]byte("d"), lo.ToPtr(3))
+	current := txn.Commit()
+
+	delta := &bytes.Buffer{}
+	require.NoError(t, WriteDelta[int](delta, base, current, intCodec{}))
+	_, err := ApplyDelta[int](other, delta, intCodec{})
+	require.ErrorIs(t, err, ErrBaseMismatch)
+}
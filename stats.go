@@ -0,0 +1,134 @@
+package iradix
+
+import "unsafe"
+
+// Stats describes the shape and the memory usage of the tree.
+type Stats struct {
+	// Nodes is the total number of nodes.
+	Nodes int
+
+	// Leaves is the number of nodes without children.
+	Leaves int
+
+	// InnerNodes is the number of nodes having children.
+	InnerNodes int
+
+	// Values is the number of values stored in the tree.
+	Values int
+
+	// Fanout is the histogram of the number of children, Fanout[i] is the number of nodes having i children.
+	Fanout []int
+
+	// PrefixLengths is the histogram of prefix lengths, PrefixLengths[i] is the number of nodes having
+	// prefix of length i.
+	PrefixLengths []int
+
+	// Depths is the histogram of value depths, Depths[i] is the number of values stored in nodes
+	// i edges away from the root.
+	Depths []int
+
+	// Bytes is the estimated memory used by nodes, their prefixes, edges and values. Memory referenced
+	// by values is not included.
+	Bytes int64
+}
+
+// Sharing describes the nodes shared by two trees.
+type Sharing struct {
+	// NodesA is the number of nodes in the first tree.
+	NodesA int
+
+	// NodesB is the number of nodes in the second tree.
+	NodesB int
+
+	// SharedNodes is the number of nodes present in both trees.
+	SharedNodes int
+
+	// SharedBytes is the estimated memory used by the shared nodes.
+	SharedBytes int64
+}
+
+// Stats computes the statistics of the subtree.
+func (n *Node[T]) Stats() Stats {
+	var s Stats
+	n.stats(&s, 0)
+	return s
+}
+
+func (n *Node[T]) stats(s *Stats, depth int) {
+	es := n.children()
+
+	s.Nodes++
+	if es.len() == 0 {
+		s.Leaves++
+	} else {
+		s.InnerNodes++
+	}
+	if n.hasValue() {
+		s.Values++
+		s.Depths = increment(s.Depths, depth)
+	}
+	s.Fanout = increment(s.Fanout, es.len())
+	s.PrefixLengths = increment(s.PrefixLengths, len(n.prefix))
+	s.Bytes += n.size()
+
+	for _, child := range es.all() {
+		child.stats(s, depth+1)
+	}
+}
+
+// SharedStats reports how many nodes are shared by trees a and b. Nodes are shared when new version
+// of the tree is created from the previous one, so it tells how much memory is pinned by keeping
+// the old version.
+func SharedStats[T any](a, b *Node[T]) Sharing {
+	var s Sharing
+	nodesA := map[*Node[T]]struct{}{}
+	a.visit(func(n *Node[T]) bool {
+		nodesA[n] = struct{}{}
+		s.NodesA++
+		return true
+	})
+	b.visit(func(n *Node[T]) bool {
+		s.NodesB++
+		if _, exists := nodesA[n]; !exists {
+			return true
+		}
+		// Nodes are immutable, so the whole subtree is shared.
+		subtree := n.Stats()
+		s.NodesB += subtree.Nodes - 1
+		s.SharedNodes += subtree.Nodes
+		s.SharedBytes += subtree.Bytes
+		return false
+	})
+	return s
+}
+
+// visit calls fn for each node of the subtree in pre-order. Children are not visited if fn returns false.
+func (n *Node[T]) visit(fn func(n *Node[T]) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.children().all() {
+		child.visit(fn)
+	}
+}
+
+// size returns the estimated memory used by the node.
+func (n *Node[T]) size() int64 {
+	size := int64(unsafe.Sizeof(*n))
+	if len(n.prefix) > inlinePrefixSize {
+		size += int64(len(n.prefix))
+	}
+	size += int64(cap(n.edges.list)) * int64(unsafe.Sizeof(edge[T]{}))
+	if n.edges.dense != nil {
+		size += int64(unsafe.Sizeof(*n.edges.dense))
+	}
+	return size
+}
+
+func increment(histogram []int, i int) []int {
+	for len(histogram) <= i {
+		histogram = append(histogram, 0)
+	}
+	histogram[i]++
+	return histogram
+}
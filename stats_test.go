@@ -0,0 +1,44 @@
+package iradix
+
+import (
+	"testing"
+	"unsafe"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	r := buildTree("a", "ab", "ac", "b")
+	s := r.Stats()
+	require.Equal(t, 5, s.Nodes)
+	require.Equal(t, 3, s.Leaves)
+	require.Equal(t, 2, s.InnerNodes)
+	require.Equal(t, 4, s.Values)
+	require.Equal(t, []int{3, 0, 2}, s.Fanout)
+	require.Equal(t, []int{1, 4}, s.PrefixLengths)
+	require.Equal(t, []int{0, 2, 2}, s.Depths)
+	require.Greater(t, s.Bytes, int64(5*unsafe.Sizeof(Node[int]{})))
+
+	require.Equal(t, Stats{Nodes: 1, Leaves: 1, Fanout: []int{1}, PrefixLengths: []int{1},
+		Bytes: int64(unsafe.Sizeof(Node[int]{}))}, New[int]().Stats())
+}
+
+func TestSharedStats(t *testing.T) {
+	v1 := buildTree("a", "ab", "ac", "b")
+	txn := NewTxn(v1)
+	txn.Insert([]byte("ad"), lo.ToPtr(10))
+	v2 := txn.Commit()
+
+	s := SharedStats(v1, v2)
+	require.Equal(t, 5, s.NodesA)
+	require.Equal(t, 6, s.NodesB)
+	require.Equal(t, 3, s.SharedNodes)
+	require.Equal(t, 3*int64(unsafe.Sizeof(Node[int]{})), s.SharedBytes)
+
+	s = SharedStats(v1, v1)
+	require.Equal(t, Sharing{NodesA: 5, NodesB: 5, SharedNodes: 5, SharedBytes: v1.Stats().Bytes}, s)
+
+	s = SharedStats(v1, buildTree("a", "ab", "ac", "b"))
+	require.Zero(t, s.SharedNodes)
+}
@@ -0,0 +1,248 @@
+package iradix
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	storeSnapshotFile = "snapshot"
+	storeWALFile      = "wal"
+)
+
+// ErrStaleTxn is returned if transaction committed to the store was not created for its current root.
+var ErrStaleTxn = errors.New("transaction was not created for the current root")
+
+// StoreConfig is the configuration of the persistent store.
+type StoreConfig struct {
+	// Sync defines when the log is synced to the disk.
+	Sync SyncPolicy
+
+	// SyncInterval is the minimal time between syncs if Sync is SyncPeriodic.
+	SyncInterval time.Duration
+}
+
+// Store keeps the current root of the tree. Readers access the root without any locking,
+// while writers are serialized, so transactions never overwrite each other's changes.
+// Store opened by OpenStore persists every committed transaction in the write-ahead log.
+type Store[T any] struct {
+	dir   string
+	codec ValueCodec[T]
+	root  atomic.Pointer[Node[T]]
+
+	// mu serializes writers.
+	mu      sync.Mutex
+	wal     *wal[T]
+	history *History[T]
+	subs    map[*Subscription[T]]struct{}
+	err     error
+}
+
+// NewStore creates in-memory store with the given root.
+func NewStore[T any](root *Node[T]) *Store[T] {
+	s := &Store[T]{}
+	s.root.Store(root)
+	return s
+}
+
+// OpenStore opens the store persisted in dir, creating the directory if needed.
+func OpenStore[T any](dir string, codec ValueCodec[T], config StoreConfig) (*Store[T], error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	root := New[T]()
+	data, err := os.ReadFile(filepath.Join(dir, storeSnapshotFile))
+	switch {
+	case err == nil:
+		if root, err = ReadSnapshot(bytes.NewReader(data), codec); err != nil {
+			return nil, err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, err
+	}
+
+	w, root, err := openWAL(filepath.Join(dir, storeWALFile), codec, config, root)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store[T]{
+		dir:   dir,
+		codec: codec,
+		wal:   w,
+	}
+	s.root.Store(root)
+	return s, nil
+}
+
+// Root returns the current root of the tree.
+func (s *Store[T]) Root() *Node[T] {
+	return s.root.Load()
+}
+
+// Read calls fn with the current root. Root is immutable, so fn observes consistent state
+// of the tree regardless of the concurrent updates.
+func (s *Store[T]) Read(fn func(root *Node[T])) {
+	fn(s.root.Load())
+}
+
+// Update calls fn with the transaction created for the current root and commits it if fn returns nil.
+// Updates are serialized, so no other transaction is committed between creating the transaction
+// and committing it.
+func (s *Store[T]) Update(fn func(txn *Txn[T]) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+
+	txn := NewTxn(s.root.Load())
+	if err := fn(txn); err != nil {
+		return err
+	}
+	_, err := s.commit(txn)
+	return err
+}
+
+// NewTxn creates new transaction for the current root.
+func (s *Store[T]) NewTxn() *Txn[T] {
+	return NewTxn(s.Root())
+}
+
+// Commit commits the transaction, stores its changes in the log and returns the new root.
+// Transaction must have been created for the current root of the store.
+func (s *Store[T]) Commit(txn *Txn[T]) (*Node[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return nil, s.err
+	}
+	if txn.base != s.root.Load() {
+		return nil, ErrStaleTxn
+	}
+	return s.commit(txn)
+}
+
+func (s *Store[T]) commit(txn *Txn[T]) (*Node[T], error) {
+	root := txn.Commit()
+	if s.wal != nil {
+		if err := s.wal.append(s.root.Load(), root); err != nil {
+			if s.wal.err != nil {
+				s.err = s.wal.err
+			}
+			return nil, err
+		}
+	}
+	s.root.Store(root)
+	if s.history != nil {
+		if err := s.history.Add(root); err != nil {
+			// Revision of the root received from replication leader might not be greater than the local one.
+			// Retained versions don't precede such root, so history starts again from it.
+			s.history.reset(root)
+		}
+	}
+	for sub := range s.subs {
+		sub.push(root)
+	}
+	return root, nil
+}
+
+// TrackHistory adds the current root and all the roots committed later to the history.
+func (s *Store[T]) TrackHistory(h *History[T]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := h.Add(s.root.Load()); err != nil {
+		return err
+	}
+	s.history = h
+	return nil
+}
+
+// Sync syncs the log to the disk. It does nothing for in-memory store.
+func (s *Store[T]) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.sync()
+}
+
+// Checkpoint stores the snapshot of the current root and truncates the log.
+// It does nothing for in-memory store.
+func (s *Store[T]) Checkpoint() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if s.wal == nil {
+		return nil
+	}
+
+	path := filepath.Join(s.dir, storeSnapshotFile)
+	tmpPath := path + ".tmp"
+	if err := writeSnapshotFile(tmpPath, s.root.Load(), s.codec); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if err := syncDir(s.dir); err != nil {
+		return err
+	}
+
+	// Crash before the log is truncated is fine, because replaying the log on top of the snapshot
+	// containing its changes produces the same tree.
+	if err := s.wal.truncate(); err != nil {
+		// State of the log is unknown, so it's not safe to append to it anymore.
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+// Close closes the store.
+func (s *Store[T]) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err == nil {
+		s.err = errors.New("store is closed")
+	}
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.close()
+}
+
+func writeSnapshotFile[T any](path string, root *Node[T], codec ValueCodec[T]) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if err := WriteSnapshot(f, root, codec); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return errors.Join(f.Sync(), f.Close())
+}
+
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(f.Sync(), f.Close())
+}
@@ -0,0 +1,309 @@
+package iradix
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoreRecovery(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+
+	expected := map[string]int{}
+	commit := func(from, to int) {
+		txn := s.NewTxn()
+		for i := from; i < to; i++ {
+			k := fmt.Sprintf("key/%04d", i)
+			txn.Insert([]byte(k), lo.ToPtr(i))
+			expected[k] = i
+		}
+		txn.Delete([]byte(fmt.Sprintf("key/%04d", from/2)))
+		delete(expected, fmt.Sprintf("key/%04d", from/2))
+		_, err := s.Commit(txn)
+		require.NoError(t, err)
+	}
+
+	commit(0, 100)
+	commit(50, 150)
+	require.NoError(t, s.Close())
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{Sync: SyncNever})
+	require.NoError(t, err)
+	require.Equal(t, expected, collect(t, s.Root()))
+
+	require.NoError(t, s.Checkpoint())
+	commit(150, 200)
+	require.NoError(t, s.Close())
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{Sync: SyncPeriodic})
+	require.NoError(t, err)
+	require.Equal(t, expected, collect(t, s.Root()))
+	require.NoError(t, s.Close())
+}
+
+func TestStoreTornLog(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+
+	txn := s.NewTxn()
+	txn.Insert([]byte("a"), lo.ToPtr(1))
+	_, err = s.Commit(txn)
+	require.NoError(t, err)
+
+	walPath := filepath.Join(dir, storeWALFile)
+	info, err := os.Stat(walPath)
+	require.NoError(t, err)
+	validSize := info.Size()
+
+	txn = s.NewTxn()
+	txn.Insert([]byte("b"), lo.ToPtr(2))
+	_, err = s.Commit(txn)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	// Simulate crash in the middle of writing the second record.
+	require.NoError(t, os.Truncate(walPath, validSize+5))
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, collect(t, s.Root()))
+
+	info, err = os.Stat(walPath)
+	require.NoError(t, err)
+	require.Equal(t, validSize, info.Size())
+
+	// Records appended after recovery are not lost.
+	txn = s.NewTxn()
+	txn.Insert([]byte("c"), lo.ToPtr(3))
+	_, err = s.Commit(txn)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1, "c": 3}, collect(t, s.Root()))
+	require.NoError(t, s.Close())
+}
+
+func TestStoreFailedSync(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	file := &faultyFile{walFile: s.wal.file}
+	s.wal.file = file
+
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("a"), lo.ToPtr(1))
+		return nil
+	}))
+	root := s.Root()
+
+	// Record is written, but sync fails, so it must be removed from the log.
+	file.syncErr = errors.New("sync failed")
+	err = s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("b"), lo.ToPtr(2))
+		return nil
+	})
+	require.ErrorIs(t, err, file.syncErr)
+	require.Same(t, root, s.Root())
+
+	// Log has been restored, so store accepts next commits.
+	file.syncErr = nil
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("c"), lo.ToPtr(3))
+		return nil
+	}))
+	require.NoError(t, s.Close())
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1, "c": 3}, collect(t, s.Root()))
+	require.NoError(t, s.Close())
+}
+
+func TestStoreBrokenLog(t *testing.T) {
+	dir := t.TempDir()
+	s, err := OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	file := &faultyFile{walFile: s.wal.file}
+	s.wal.file = file
+
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("a"), lo.ToPtr(1))
+		return nil
+	}))
+	root := s.Root()
+
+	// Part of the record is written and it can't be removed.
+	errWrite := errors.New("write failed")
+	file.writeErr = errWrite
+	file.truncateErr = errors.New("truncate failed")
+	err = s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("b"), lo.ToPtr(2))
+		return nil
+	})
+	require.ErrorIs(t, err, file.writeErr)
+	require.ErrorIs(t, err, file.truncateErr)
+	require.Same(t, root, s.Root())
+
+	// Records appended after the torn one would be lost on replay, so store refuses next commits.
+	file.writeErr = nil
+	file.truncateErr = nil
+	err = s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("c"), lo.ToPtr(3))
+		return nil
+	})
+	require.ErrorIs(t, err, errWrite)
+	require.Same(t, root, s.Root())
+	require.NoError(t, s.Close())
+
+	s, err = OpenStore[int](dir, intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"a": 1}, collect(t, s.Root()))
+	require.NoError(t, s.Close())
+}
+
+func TestStoreStaleTxn(t *testing.T) {
+	s, err := OpenStore[int](t.TempDir(), intCodec{}, StoreConfig{})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, s.Close())
+	}()
+
+	txn1 := s.NewTxn()
+	txn2 := s.NewTxn()
+	txn1.Insert([]byte("a"), lo.ToPtr(1))
+	txn2.Insert([]byte("b"), lo.ToPtr(2))
+
+	_, err = s.Commit(txn1)
+	require.NoError(t, err)
+	_, err = s.Commit(txn2)
+	require.ErrorIs(t, err, ErrStaleTxn)
+	require.Equal(t, map[string]int{"a": 1}, collect(t, s.Root()))
+}
+
+func TestStoreConcurrentReadsAndUpdates(t *testing.T) {
+	const (
+		writers = 4
+		updates = 200
+		readers = 8
+	)
+
+	s := NewStore(New[int]())
+	counterKey := []byte("counter")
+
+	var readersWG, writersWG sync.WaitGroup
+	done := make(chan struct{})
+	for range readers {
+		readersWG.Add(1)
+		go func() {
+			defer readersWG.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				s.Read(func(root *Node[int]) {
+					// Counter always matches the number of keys inserted by the same transaction.
+					expected := 0
+					if v := root.Get(counterKey); v != nil {
+						expected = *v
+					}
+					it := root.Iterator()
+					it.SeekPrefix([]byte("key/"))
+					assert.Len(t, iterateAll(it.Next), expected)
+				})
+			}
+		}()
+	}
+
+	for w := range writers {
+		writersWG.Add(1)
+		go func() {
+			defer writersWG.Done()
+			for i := range updates {
+				err := s.Update(func(txn *Txn[int]) error {
+					counter := 0
+					if v := txn.Get(counterKey); v != nil {
+						counter = *v
+					}
+					txn.Insert([]byte(fmt.Sprintf("key/%d/%d", w, i)), lo.ToPtr(i))
+					txn.Insert(counterKey, lo.ToPtr(counter+1))
+					return nil
+				})
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+
+	require.Equal(t, writers*updates, *s.Root().Get(counterKey))
+}
+
+func TestStoreUpdateError(t *testing.T) {
+	s := NewStore(New[int]())
+	root := s.Root()
+
+	errTest := errors.New("test")
+	err := s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("a"), lo.ToPtr(1))
+		return errTest
+	})
+	require.ErrorIs(t, err, errTest)
+	require.Same(t, root, s.Root())
+
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		txn.Insert([]byte("a"), lo.ToPtr(1))
+		return nil
+	}))
+	require.Equal(t, 1, *s.Root().Get([]byte("a")))
+}
+
+// faultyFile returns configured errors. Failed write stores half of the data.
+type faultyFile struct {
+	walFile
+
+	writeErr    error
+	syncErr     error
+	truncateErr error
+}
+
+func (f *faultyFile) Write(b []byte) (int, error) {
+	if f.writeErr != nil {
+		n, err := f.walFile.Write(b[:len(b)/2])
+		if err != nil {
+			return n, err
+		}
+		return n, f.writeErr
+	}
+	return f.walFile.Write(b)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.syncErr != nil {
+		return f.syncErr
+	}
+	return f.walFile.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.truncateErr != nil {
+		return f.truncateErr
+	}
+	return f.walFile.Truncate(size)
+}
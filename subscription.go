@@ -0,0 +1,201 @@
+package iradix
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// DefaultMaxPending is the default number of commits buffered by the subscription.
+const DefaultMaxPending = 1024
+
+var (
+	// ErrSubscriptionClosed is returned when reading from closed subscription.
+	ErrSubscriptionClosed = errors.New("subscription is closed")
+
+	// ErrRevisionUnavailable is returned if subscription is resumed from revision not retained anymore.
+	ErrRevisionUnavailable = errors.New("revision is not available")
+)
+
+// Batch is the set of changes of keys having the subscribed prefix.
+type Batch[T any] struct {
+	// PrevRevision is the revision of the previous batch, or the revision subscription started from.
+	PrevRevision uint64
+
+	// Revision is the revision of the tree after the changes. It might be greater than
+	// PrevRevision + 1 if commits not touching the prefix were skipped or if subscriber was slow,
+	// causing changes of consecutive commits to be merged.
+	Revision uint64
+
+	// Changes are the changes ordered by key.
+	Changes []Change[T]
+}
+
+// SubscriptionConfig is the configuration of the subscription.
+type SubscriptionConfig struct {
+	// MaxPending is the maximum number of commits buffered for the subscriber. When exceeded,
+	// the oldest buffered commits are merged. Zero means DefaultMaxPending.
+	MaxPending int
+}
+
+// Subscription delivers changes of keys having the prefix, committed to the store.
+// Changes are computed lazily by comparing the consecutive roots, so pending commits cost only
+// the nodes not shared with other versions.
+type Subscription[T any] struct {
+	store      *Store[T]
+	prefix     []byte
+	maxPending int
+	notify     chan struct{}
+
+	mu sync.Mutex
+	// roots[0] is the root already delivered to the subscriber, the rest are pending.
+	roots  []*Node[T]
+	closed bool
+
+	// revision is the revision of the last delivered batch.
+	revision uint64
+}
+
+// Subscribe subscribes for changes of keys having the prefix, starting from the current root.
+func (s *Store[T]) Subscribe(prefix []byte, config SubscriptionConfig) *Subscription[T] {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.subscribe(prefix, config, s.root.Load())
+}
+
+// SubscribeFrom subscribes for changes of keys having the prefix committed after the revision.
+// Store must track the history containing the revision.
+func (s *Store[T]) SubscribeFrom(prefix []byte, revision uint64, config SubscriptionConfig) (*Subscription[T], error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.history == nil {
+		return nil, ErrRevisionUnavailable
+	}
+	from := s.history.AsOf(revision)
+	if from == nil {
+		return nil, ErrRevisionUnavailable
+	}
+	sub := s.subscribe(prefix, config, from)
+	for _, root := range s.history.after(revision) {
+		sub.push(root)
+	}
+	sub.push(s.root.Load())
+	return sub, nil
+}
+
+func (s *Store[T]) subscribe(prefix []byte, config SubscriptionConfig, root *Node[T]) *Subscription[T] {
+	if config.MaxPending <= 0 {
+		config.MaxPending = DefaultMaxPending
+	}
+	sub := &Subscription[T]{
+		store:      s,
+		prefix:     copyPrefix(prefix),
+		maxPending: config.MaxPending,
+		notify:     make(chan struct{}, 1),
+		roots:      []*Node[T]{root},
+		revision:   root.revision,
+	}
+	if s.subs == nil {
+		s.subs = map[*Subscription[T]]struct{}{}
+	}
+	s.subs[sub] = struct{}{}
+	return sub
+}
+
+// root returns the root already delivered to the subscriber.
+func (sub *Subscription[T]) root() *Node[T] {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	return sub.roots[0]
+}
+
+// Next returns the next batch of changes, waiting until it's available.
+// Next must not be called concurrently.
+func (sub *Subscription[T]) Next(ctx context.Context) (Batch[T], error) {
+	for {
+		batch, ok, err := sub.next()
+		if err != nil || ok {
+			return batch, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return Batch[T]{}, ctx.Err()
+		case <-sub.notify:
+		}
+	}
+}
+
+// Close closes the subscription.
+func (sub *Subscription[T]) Close() {
+	s := sub.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subs, sub)
+
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	sub.closed = true
+	sub.roots = nil
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (sub *Subscription[T]) next() (Batch[T], bool, error) {
+	for {
+		sub.mu.Lock()
+		if sub.closed {
+			sub.mu.Unlock()
+			return Batch[T]{}, false, ErrSubscriptionClosed
+		}
+		if len(sub.roots) < 2 {
+			sub.mu.Unlock()
+			return Batch[T]{}, false, nil
+		}
+		from, to := sub.roots[0], sub.roots[1]
+		sub.roots[0] = nil
+		sub.roots = sub.roots[1:]
+		sub.mu.Unlock()
+
+		// Diff is computed without holding the lock, so writers are not blocked.
+		if changes := DiffPrefix(from, to, sub.prefix); len(changes) > 0 {
+			b := Batch[T]{
+				PrevRevision: sub.revision,
+				Revision:     to.revision,
+				Changes:      changes,
+			}
+			sub.revision = to.revision
+			return b, true, nil
+		}
+	}
+}
+
+// push adds committed root to the subscription.
+func (sub *Subscription[T]) push(root *Node[T]) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	if sub.roots[len(sub.roots)-1] == root {
+		return
+	}
+	if len(sub.roots) > sub.maxPending {
+		// Subscriber is too slow, so the two oldest pending commits are merged.
+		copy(sub.roots[1:], sub.roots[2:])
+		sub.roots[len(sub.roots)-1] = nil
+		sub.roots = sub.roots[:len(sub.roots)-1]
+	}
+	sub.roots = append(sub.roots, root)
+
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,122 @@
+package iradix
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func insertInStore(t *testing.T, s *Store[int], keys ...string) {
+	require.NoError(t, s.Update(func(txn *Txn[int]) error {
+		for _, k := range keys {
+			txn.Insert([]byte(k), lo.ToPtr(len(k)))
+		}
+		return nil
+	}))
+}
+
+func batchKeys(b Batch[int]) []string {
+	keys := make([]string, 0, len(b.Changes))
+	for _, c := range b.Changes {
+		keys = append(keys, string(c.Key))
+	}
+	return keys
+}
+
+func TestSubscription(t *testing.T) {
+	ctx := context.Background()
+	s := NewStore(New[int]())
+	sub := s.Subscribe([]byte("a/"), SubscriptionConfig{})
+
+	insertInStore(t, s, "a/1", "b/1")
+	insertInStore(t, s, "b/2")
+	insertInStore(t, s, "a/2", "a/3")
+
+	b, err := sub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), b.PrevRevision)
+	require.Equal(t, uint64(1), b.Revision)
+	require.Equal(t, []string{"a/1"}, batchKeys(b))
+
+	b, err = sub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), b.PrevRevision)
+	require.Equal(t, uint64(3), b.Revision)
+	require.Equal(t, []string{"a/2", "a/3"}, batchKeys(b))
+
+	// Next waits for the commit.
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		assert.NoError(t, s.Update(func(txn *Txn[int]) error {
+			txn.Insert([]byte("a/4"), lo.ToPtr(3))
+			return nil
+		}))
+	}()
+	b, err = sub.Next(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a/4"}, batchKeys(b))
+
+	ctx2, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = sub.Next(ctx2)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	sub.Close()
+	_, err = sub.Next(ctx)
+	require.ErrorIs(t, err, ErrSubscriptionClosed)
+	insertInStore(t, s, "a/5")
+}
+
+func TestSubscriptionBackpressure(t *testing.T) {
+	s := NewStore(New[int]())
+	sub := s.Subscribe(nil, SubscriptionConfig{MaxPending: 2})
+
+	insertInStore(t, s, "a")
+	insertInStore(t, s, "b")
+	insertInStore(t, s, "c")
+	insertInStore(t, s, "d")
+
+	// The oldest pending commits are merged.
+	b, err := sub.Next(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), b.PrevRevision)
+	require.Equal(t, uint64(3), b.Revision)
+	require.Equal(t, []string{"a", "b", "c"}, batchKeys(b))
+
+	b, err = sub.Next(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), b.PrevRevision)
+	require.Equal(t, uint64(4), b.Revision)
+	require.Equal(t, []string{"d"}, batchKeys(b))
+}
+
+func TestSubscriptionResume(t *testing.T) {
+	s := NewStore(New[int]())
+
+	_, err := s.SubscribeFrom(nil, 0, SubscriptionConfig{})
+	require.ErrorIs(t, err, ErrRevisionUnavailable)
+
+	require.NoError(t, s.TrackHistory(NewHistory[int](HistoryConfig{MaxVersions: 3})))
+	insertInStore(t, s, "a")
+	insertInStore(t, s, "b")
+	insertInStore(t, s, "c")
+
+	_, err = s.SubscribeFrom(nil, 0, SubscriptionConfig{})
+	require.ErrorIs(t, err, ErrRevisionUnavailable)
+
+	sub, err := s.SubscribeFrom(nil, 1, SubscriptionConfig{})
+	require.NoError(t, err)
+	defer sub.Close()
+
+	insertInStore(t, s, "d")
+	for _, expected := range []string{"b", "c", "d"} {
+		b, err := sub.Next(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, b.PrevRevision+1, b.Revision)
+		require.Equal(t, []string{expected}, batchKeys(b))
+	}
+}
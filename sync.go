@@ -0,0 +1,350 @@
+package iradix
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Sync protocol consists of requests sent by the client and responses sent by the server,
+// both using the frames of the replication stream.
+//
+// msgSyncSummaries request contains the list of prefixes. Response contains, for each prefix,
+// the summary of the subtree storing keys having it: the full key of its node, its hash, the hash
+// of its value and the labels and hashes of its children.
+//
+// msgSyncFetch request contains the list of prefixes, each one flagged as exact or not. Response contains
+// the insert operations, encoded the same way as in the write-ahead log, for the keys having the prefix
+// or, if it is exact, for the key equal to it.
+
+const (
+	msgSyncSummaries byte = 16
+	msgSyncFetch     byte = 17
+)
+
+type syncSummary struct {
+	exists    bool
+	path      []byte
+	hash      Hash
+	valueHash *Hash
+	labels    []byte
+	children  []Hash
+}
+
+func (s syncSummary) equal(s2 syncSummary) bool {
+	return s.exists == s2.exists && bytes.Equal(s.path, s2.path) && s.hash == s2.hash
+}
+
+func (s syncSummary) child(label byte) (Hash, bool) {
+	idx := bytes.IndexByte(s.labels, label)
+	if idx < 0 {
+		return Hash{}, false
+	}
+	return s.children[idx], true
+}
+
+type syncFetch struct {
+	prefix []byte
+	exact  bool
+}
+
+// ServeSync serves the sync requests sent by the client connected by conn, for the tree.
+// It returns nil when client closes the connection.
+func ServeSync[T any](conn io.ReadWriter, root *Node[T], codec ValueCodec[T], hasher ValueHasher[T]) error {
+	ops := opEncoder[T]{codec: codec}
+	var buf, resp []byte
+	for {
+		msgType, payload, err := readFrame(conn, buf)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		buf = payload
+
+		resp = resp[:0]
+		switch msgType {
+		case msgSyncSummaries:
+			prefixes, err := decodePrefixes(payload)
+			if err != nil {
+				return err
+			}
+			for _, p := range prefixes {
+				resp = appendSummary(resp, summarize(root, p, hasher))
+			}
+		case msgSyncFetch:
+			fetches, err := decodeFetches(payload)
+			if err != nil {
+				return err
+			}
+			for _, f := range fetches {
+				if f.exact {
+					if v := root.Get(f.prefix); v != nil {
+						resp = ops.append(resp, f.prefix, v)
+					}
+					continue
+				}
+				_ = root.walk(f.prefix, prefixEnd(f.prefix), func(k []byte, v *T) error {
+					resp = ops.append(resp, k, v)
+					return nil
+				})
+			}
+		default:
+			return fmt.Errorf("unexpected message %d: %w", msgType, ErrProtocol)
+		}
+		if err := writeFrame(conn, msgType, resp); err != nil {
+			return err
+		}
+	}
+}
+
+// Sync makes the local tree identical to the one served by ServeSync on the other side of conn.
+// Hashes of the subtrees are compared at increasing depth, so only the differing key ranges
+// are transferred.
+func Sync[T any](local *Node[T], conn io.ReadWriter, codec ValueCodec[T], hasher ValueHasher[T]) (*Node[T], error) {
+	var fetches, deletes []syncFetch
+	pending := [][]byte{nil}
+	for len(pending) > 0 {
+		remote, err := requestSummaries(conn, pending)
+		if err != nil {
+			return nil, err
+		}
+
+		var next [][]byte
+		for i, p := range pending {
+			r := remote[i]
+			l := summarize(local, p, hasher)
+			switch {
+			case r.equal(l):
+			case !r.exists:
+				deletes = append(deletes, syncFetch{prefix: p})
+			case !l.exists:
+				fetches = append(fetches, syncFetch{prefix: p})
+			default:
+				var fetch, del bool
+				next, fetch, del = syncDescend(next, l, r)
+				switch {
+				case fetch:
+					fetches = append(fetches, syncFetch{prefix: longestCommon(l.path, r.path), exact: true})
+				case del:
+					deletes = append(deletes, syncFetch{prefix: longestCommon(l.path, r.path), exact: true})
+				}
+			}
+		}
+		pending = next
+	}
+
+	txn := NewTxn(local)
+	for _, d := range deletes {
+		deleteLocal(txn, local, d)
+	}
+	if len(fetches) == 0 {
+		return txn.Commit(), nil
+	}
+	for _, f := range fetches {
+		deleteLocal(txn, local, f)
+	}
+
+	if err := writeFrame(conn, msgSyncFetch, encodeFetches(fetches)); err != nil {
+		return nil, err
+	}
+	msgType, payload, err := readFrame(conn, nil)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if msgType != msgSyncFetch {
+		return nil, fmt.Errorf("unexpected message %d: %w", msgType, ErrProtocol)
+	}
+	if err := applyOps(txn, codec, payload); err != nil {
+		return nil, err
+	}
+	return txn.Commit(), nil
+}
+
+// syncDescend returns the prefixes to compare at the next level, for the subtrees which differ.
+// Subtrees are compared starting at the common part of their paths. Node whose path is equal to it
+// contributes its children, the other one contributes the next byte of its path. It also reports if
+// the value stored under the common path must be fetched, or deleted locally because remote doesn't store it.
+func syncDescend(next [][]byte, l, r syncSummary) ([][]byte, bool, bool) {
+	common := longestCommon(l.path, r.path)
+
+	var labels []byte
+	for _, s := range []syncSummary{l, r} {
+		if len(s.path) == len(common) {
+			labels = append(labels, s.labels...)
+		} else {
+			labels = append(labels, s.path[len(common)])
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i] < labels[j] })
+
+	for i, label := range labels {
+		if i > 0 && labels[i-1] == label {
+			continue
+		}
+		if len(l.path) == len(common) && len(r.path) == len(common) {
+			lHash, lExists := l.child(label)
+			rHash, rExists := r.child(label)
+			if lExists && rExists && lHash == rHash {
+				continue
+			}
+		}
+		next = append(next, append(copyPrefix(common), label))
+	}
+
+	lValue := len(l.path) == len(common) && l.valueHash != nil
+	rValue := len(r.path) == len(common) && r.valueHash != nil
+	return next, rValue && (!lValue || *l.valueHash != *r.valueHash), lValue && !rValue
+}
+
+func longestCommon(a, b []byte) []byte {
+	return a[:longestPrefix(a, b)]
+}
+
+func deleteLocal[T any](txn *Txn[T], local *Node[T], f syncFetch) {
+	if f.exact {
+		txn.Delete(f.prefix)
+		return
+	}
+	var keys [][]byte
+	_ = local.walk(f.prefix, prefixEnd(f.prefix), func(k []byte, _ *T) error {
+		keys = append(keys, copyPrefix(k))
+		return nil
+	})
+	for _, k := range keys {
+		txn.Delete(k)
+	}
+}
+
+func summarize[T any](root *Node[T], prefix []byte, hasher ValueHasher[T]) syncSummary {
+	n, path := prefixNode(root, prefix)
+	if n == nil || (!n.hasValue() && n.children().len() == 0) {
+		return syncSummary{}
+	}
+	es := n.children()
+	s := syncSummary{
+		exists:    true,
+		path:      path,
+		hash:      n.Hash(hasher),
+		valueHash: n.valueHash(hasher),
+		labels:    make([]byte, 0, es.len()),
+		children:  make([]Hash, 0, es.len()),
+	}
+	for label, child := range es.all() {
+		s.labels = append(s.labels, label)
+		s.children = append(s.children, child.Hash(hasher))
+	}
+	return s
+}
+
+func requestSummaries(conn io.ReadWriter, prefixes [][]byte) ([]syncSummary, error) {
+	var b []byte
+	b = binary.AppendUvarint(b, uint64(len(prefixes)))
+	for _, p := range prefixes {
+		b = binary.AppendUvarint(b, uint64(len(p)))
+		b = append(b, p...)
+	}
+	if err := writeFrame(conn, msgSyncSummaries, b); err != nil {
+		return nil, err
+	}
+
+	msgType, payload, err := readFrame(conn, nil)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if msgType != msgSyncSummaries {
+		return nil, fmt.Errorf("unexpected message %d: %w", msgType, ErrProtocol)
+	}
+
+	summaries := make([]syncSummary, 0, len(prefixes))
+	d := byteDecoder{data: payload}
+	for range prefixes {
+		var s syncSummary
+		if s.exists = d.bytes(1)[0] == 1; s.exists {
+			s.path = copyPrefix(d.bytes(int(d.uvarint(len(d.data)))))
+			copy(s.hash[:], d.bytes(sha256.Size))
+			if d.bytes(1)[0] == 1 {
+				s.valueHash = &Hash{}
+				copy(s.valueHash[:], d.bytes(sha256.Size))
+			}
+			numChildren := int(d.uvarint(256))
+			s.labels = make([]byte, numChildren)
+			s.children = make([]Hash, numChildren)
+			for i := range numChildren {
+				s.labels[i] = d.bytes(1)[0]
+				copy(s.children[i][:], d.bytes(sha256.Size))
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	if d.failed {
+		return nil, fmt.Errorf("invalid summaries: %w", ErrProtocol)
+	}
+	return summaries, nil
+}
+
+func appendSummary(b []byte, s syncSummary) []byte {
+	if !s.exists {
+		return append(b, 0)
+	}
+	b = append(b, 1)
+	b = binary.AppendUvarint(b, uint64(len(s.path)))
+	b = append(b, s.path...)
+	b = append(b, s.hash[:]...)
+	if s.valueHash == nil {
+		b = append(b, 0)
+	} else {
+		b = append(b, 1)
+		b = append(b, s.valueHash[:]...)
+	}
+	b = binary.AppendUvarint(b, uint64(len(s.labels)))
+	for i, label := range s.labels {
+		b = append(b, label)
+		b = append(b, s.children[i][:]...)
+	}
+	return b
+}
+
+func decodePrefixes(payload []byte) ([][]byte, error) {
+	d := byteDecoder{data: payload}
+	prefixes := make([][]byte, d.uvarint(len(payload)))
+	for i := range prefixes {
+		prefixes[i] = d.bytes(int(d.uvarint(len(d.data))))
+	}
+	if d.failed {
+		return nil, fmt.Errorf("invalid prefixes: %w", ErrProtocol)
+	}
+	return prefixes, nil
+}
+
+func encodeFetches(fetches []syncFetch) []byte {
+	b := binary.AppendUvarint(nil, uint64(len(fetches)))
+	for _, f := range fetches {
+		if f.exact {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		b = binary.AppendUvarint(b, uint64(len(f.prefix)))
+		b = append(b, f.prefix...)
+	}
+	return b
+}
+
+func decodeFetches(payload []byte) ([]syncFetch, error) {
+	d := byteDecoder{data: payload}
+	fetches := make([]syncFetch, d.uvarint(len(payload)))
+	for i := range fetches {
+		fetches[i].exact = d.bytes(1)[0] == 1
+		fetches[i].prefix = d.bytes(int(d.uvarint(len(d.data))))
+	}
+	if d.failed {
+		return nil, fmt.Errorf("invalid fetch request: %w", ErrProtocol)
+	}
+	return fetches, nil
+}
@@ -0,0 +1,98 @@
+package iradix
+
+import (
+	"fmt"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingConn struct {
+	io.ReadWriter
+	read int
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.ReadWriter.Read(p)
+	c.read += n
+	return n, err
+}
+
+func syncTrees(t *testing.T, local, remote *Node[int]) (*Node[int], int) {
+	serverConn, clientConn := net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, ServeSync(serverConn, remote, intCodec{}, intCodec{}.Encode))
+	}()
+
+	conn := &countingConn{ReadWriter: clientConn}
+	synced, err := Sync(local, conn, intCodec{}, intCodec{}.Encode)
+	require.NoError(t, err)
+	require.NoError(t, clientConn.Close())
+	<-done
+
+	require.Equal(t, collect(t, remote), collect(t, synced))
+	require.Equal(t, remote.Hash(intCodec{}.Encode), synced.Hash(intCodec{}.Encode))
+	return synced, conn.read
+}
+
+func TestSync(t *testing.T) {
+	rand := mathrand.New(mathrand.NewSource(1))
+	randomTree := func(base *Node[int], ops int) *Node[int] {
+		txn := NewTxn(base)
+		for range ops {
+			k := []byte(randString(rand))
+			if rand.Intn(3) == 0 {
+				txn.Delete(k)
+				continue
+			}
+			txn.Insert(k, lo.ToPtr(rand.Intn(3)))
+		}
+		return txn.Commit()
+	}
+
+	for i := range 50 {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			local := randomTree(New[int](), 200)
+			syncTrees(t, local, randomTree(local, rand.Intn(10)))
+			syncTrees(t, local, randomTree(New[int](), 200))
+		})
+	}
+
+	syncTrees(t, New[int](), buildTree("a", "b"))
+	syncTrees(t, buildTree("a", "b"), New[int]())
+	syncTrees(t, buildTree("abc"), buildTree("abd"))
+	syncTrees(t, buildTree("", "abc"), buildTree("ab", "abc"))
+
+	// Value existing only locally is deleted without fetching anything.
+	remote := NewTxn(New[int]())
+	remote.Insert([]byte("a"), lo.ToPtr(1))
+	syncTrees(t, buildTree("", "a"), remote.Commit())
+}
+
+func TestSyncTransfersDifferencesOnly(t *testing.T) {
+	txn := NewTxn(New[int]())
+	for i := range 10000 {
+		txn.Insert([]byte(fmt.Sprintf("key/%05d", i)), lo.ToPtr(i))
+	}
+	local := txn.Commit()
+
+	txn = NewTxn(local)
+	txn.Insert([]byte("key/05000"), lo.ToPtr(-1))
+	txn.Delete([]byte("key/00100"))
+	remote := txn.Commit()
+
+	_, synced := syncTrees(t, local, remote)
+	full := collect(t, local)
+	require.Less(t, synced, len(full))
+
+	// Identical trees are compared using single request.
+	_, synced = syncTrees(t, remote, remote)
+	require.Less(t, synced, 200)
+}
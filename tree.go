@@ -0,0 +1,138 @@
+package iradix
+
+// KeyCodec converts keys of type K to and from their binary representation.
+// Encoding must preserve the order, meaning that bytes.Compare on encoded keys must order them
+// the same way as original keys are ordered.
+type KeyCodec[K any] interface {
+	// Encode appends binary representation of k to buf and returns the extended buffer.
+	Encode(buf []byte, k K) []byte
+
+	// Decode decodes key from data. Implementation must not retain data.
+	Decode(data []byte) (K, error)
+}
+
+// StringKeyCodec is the key codec for string keys.
+type StringKeyCodec struct{}
+
+// Encode appends k to buf.
+func (StringKeyCodec) Encode(buf []byte, k string) []byte {
+	return append(buf, k...)
+}
+
+// Decode returns data as string.
+func (StringKeyCodec) Decode(data []byte) (string, error) {
+	return string(data), nil
+}
+
+// NewTree returns an empty tree using codec to encode keys.
+func NewTree[K, V any](codec KeyCodec[K]) *Tree[K, V] {
+	return TreeFromNode(New[V](), codec)
+}
+
+// TreeFromNode returns the tree wrapping root, keys stored under root must be encoded by codec.
+func TreeFromNode[K, V any](root *Node[V], codec KeyCodec[K]) *Tree[K, V] {
+	return &Tree[K, V]{
+		root:  root,
+		codec: codec,
+	}
+}
+
+// Tree is the immutable radix tree using keys of type K. Keys are encoded by the codec,
+// so the order of the iteration is the one defined by the codec.
+type Tree[K, V any] struct {
+	root  *Node[V]
+	codec KeyCodec[K]
+}
+
+// Root returns the underlying byte-oriented root of the tree.
+func (t *Tree[K, V]) Root() *Node[V] {
+	return t.root
+}
+
+// Get returns the value stored under the key, or nil if key does not exist.
+func (t *Tree[K, V]) Get(k K) *V {
+	return t.root.Get(t.codec.Encode(nil, k))
+}
+
+// Lookup returns the value stored under the key and true, or zero value and false if key does not exist.
+func (t *Tree[K, V]) Lookup(k K) (V, bool) {
+	return t.root.Lookup(t.codec.Encode(nil, k))
+}
+
+// Txn creates new transaction that can be used to mutate the tree.
+func (t *Tree[K, V]) Txn() *TreeTxn[K, V] {
+	return &TreeTxn[K, V]{
+		txn:   NewTxn(t.root),
+		codec: t.codec,
+	}
+}
+
+// Walk calls fn for each key and value stored in the tree, in key order.
+// Iteration stops on the first error returned by fn, and that error is returned.
+func (t *Tree[K, V]) Walk(fn func(k K, v *V) error) error {
+	return t.walk(nil, nil, fn)
+}
+
+// WalkFrom works like Walk but starts from the smallest key greater or equal to from.
+func (t *Tree[K, V]) WalkFrom(from K, fn func(k K, v *V) error) error {
+	return t.walk(t.codec.Encode(nil, from), nil, fn)
+}
+
+// WalkRange works like Walk but visits only the keys in range [from, to).
+func (t *Tree[K, V]) WalkRange(from, to K, fn func(k K, v *V) error) error {
+	return t.walk(t.codec.Encode(nil, from), t.codec.Encode(nil, to), fn)
+}
+
+func (t *Tree[K, V]) walk(from, to []byte, fn func(k K, v *V) error) error {
+	return t.root.walk(from, to, func(kb []byte, v *V) error {
+		k, err := t.codec.Decode(kb)
+		if err != nil {
+			return err
+		}
+		return fn(k, v)
+	})
+}
+
+// TreeTxn is a transaction on the tree using keys of type K. Like Txn, it is not thread safe.
+type TreeTxn[K, V any] struct {
+	txn   *Txn[V]
+	codec KeyCodec[K]
+	buf   []byte
+}
+
+// Get returns the value stored under the key within the transaction, or nil if key does not exist.
+func (t *TreeTxn[K, V]) Get(k K) *V {
+	return t.txn.Get(t.encode(k))
+}
+
+// Lookup returns the value stored under the key within the transaction and true,
+// or zero value and false if key does not exist.
+func (t *TreeTxn[K, V]) Lookup(k K) (V, bool) {
+	return t.txn.Lookup(t.encode(k))
+}
+
+// Set adds or updates the key storing a copy of v, returning the previous value and true if key existed.
+func (t *TreeTxn[K, V]) Set(k K, v V) (V, bool) {
+	return t.txn.Set(t.encode(k), v)
+}
+
+// Insert adds or updates the key, returning the previous value.
+func (t *TreeTxn[K, V]) Insert(k K, v *V) *V {
+	// Key is copied by the transaction, so the buffer might be reused.
+	return t.txn.Insert(t.encode(k), v)
+}
+
+// Delete removes the key, returning the previous value.
+func (t *TreeTxn[K, V]) Delete(k K) *V {
+	return t.txn.Delete(t.encode(k))
+}
+
+// Commit returns the tree with the changes applied.
+func (t *TreeTxn[K, V]) Commit() *Tree[K, V] {
+	return TreeFromNode(t.txn.Commit(), t.codec)
+}
+
+func (t *TreeTxn[K, V]) encode(k K) []byte {
+	t.buf = t.codec.Encode(t.buf[:0], k)
+	return t.buf
+}
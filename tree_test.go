@@ -0,0 +1,76 @@
+package iradix
+
+import (
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+type intKeyCodec struct{}
+
+func (intKeyCodec) Encode(buf []byte, k int) []byte {
+	return binary.BigEndian.AppendUint64(buf, uint64(k)^(1<<63))
+}
+
+func (intKeyCodec) Decode(data []byte) (int, error) {
+	if len(data) != 8 {
+		return 0, errors.New("invalid key")
+	}
+	return int(binary.BigEndian.Uint64(data) ^ (1 << 63)), nil
+}
+
+func walkKeys(t *testing.T, walk func(fn func(k int, v *string) error) error) []int {
+	var keys []int
+	require.NoError(t, walk(func(k int, v *string) error {
+		keys = append(keys, k)
+		return nil
+	}))
+	return keys
+}
+
+func TestTree(t *testing.T) {
+	tree := NewTree[int, string](intKeyCodec{})
+	txn := tree.Txn()
+	for _, k := range []int{5, -3, 100, 0, -100, 7} {
+		require.Nil(t, txn.Insert(k, lo.ToPtr(strconv.Itoa(k))))
+	}
+	require.Equal(t, "5", *txn.Get(5))
+	require.Equal(t, "5", *txn.Delete(5))
+	require.Nil(t, txn.Delete(5))
+	tree2 := txn.Commit()
+
+	require.Nil(t, tree.Get(7))
+	require.Nil(t, tree2.Get(5))
+	require.Equal(t, "7", *tree2.Get(7))
+	require.Equal(t, "-3", *tree2.Get(-3))
+
+	require.Equal(t, []int{-100, -3, 0, 7, 100}, walkKeys(t, tree2.Walk))
+	require.Equal(t, []int{0, 7, 100}, walkKeys(t, func(fn func(k int, v *string) error) error {
+		return tree2.WalkFrom(-2, fn)
+	}))
+	require.Equal(t, []int{-3, 0}, walkKeys(t, func(fn func(k int, v *string) error) error {
+		return tree2.WalkRange(-3, 7, fn)
+	}))
+
+	errStop := errors.New("stop")
+	var visited int
+	require.ErrorIs(t, tree2.Walk(func(k int, v *string) error {
+		visited++
+		return errStop
+	}), errStop)
+	require.Equal(t, 1, visited)
+}
+
+func TestTreeDecodeError(t *testing.T) {
+	txn := NewTxn(New[string]())
+	txn.Insert([]byte("a"), lo.ToPtr("a"))
+	tree := TreeFromNode(txn.Commit(), KeyCodec[int](intKeyCodec{}))
+	require.Error(t, tree.Walk(func(k int, v *string) error { return nil }))
+
+	strTree := TreeFromNode(tree.Root(), KeyCodec[string](StringKeyCodec{}))
+	require.Equal(t, "a", *strTree.Get("a"))
+}
@@ -0,0 +1,134 @@
+package iradix
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTree is returned if the tree violates structural invariants.
+var ErrInvalidTree = errors.New("invalid tree")
+
+// Validate checks the structural invariants of the tree rooted at n. It is meant for tests and debugging,
+// the trees produced by the package are always valid.
+// Cached hashes are only checked to be present in the children of hashed nodes, use ValidateHashes
+// to recompute them.
+func (n *Node[T]) Validate() error {
+	return n.validate(nil, true, nil)
+}
+
+// ValidateHashes checks the same invariants as Validate and additionally recomputes the cached hashes
+// using the hasher, which must be the one used to compute them.
+func (n *Node[T]) ValidateHashes(hasher ValueHasher[T]) error {
+	return n.validate(nil, true, hasher)
+}
+
+func (n *Node[T]) validate(path []byte, root bool, hasher ValueHasher[T]) error {
+	path = append(path, n.prefix...)
+	invalid := func(format string, args ...any) error {
+		return fmt.Errorf("node %q: %s: %w", path, fmt.Sprintf(format, args...), ErrInvalidTree)
+	}
+
+	es := n.children()
+	if err := es.validate(); err != nil {
+		return invalid("%s", err)
+	}
+	if !root {
+		switch {
+		case len(n.prefix) == 0:
+			return invalid("empty prefix of non-root node")
+		case !n.hasValue() && es.len() == 0:
+			return invalid("leaf without value")
+		case !n.hasValue() && es.len() == 1:
+			return invalid("inner node without value has single child")
+		}
+	}
+
+	hashed := n.hash.Load() != nil
+	prevLabel := -1
+	for label, child := range es.all() {
+		switch {
+		case int(label) <= prevLabel:
+			return invalid("edge %#x follows edge %#x", label, prevLabel)
+		case len(child.prefix) == 0 || child.prefix[0] != label:
+			return invalid("edge %#x points to child with prefix %q", label, child.prefix)
+		case child.revision > n.revision:
+			return invalid("child %#x has revision %d greater than %d", label, child.revision, n.revision)
+		case hashed && child.hash.Load() == nil:
+			return invalid("hash is cached but hash of child %#x is not", label)
+		}
+		prevLabel = int(label)
+
+		if err := child.validate(path, false, hasher); err != nil {
+			return err
+		}
+	}
+
+	if hashed && hasher != nil {
+		// Hashes of the children have been already verified, so the cached ones are used.
+		labels := make([]byte, 0, es.len())
+		childHashes := make([]Hash, 0, es.len())
+		for label, child := range es.all() {
+			labels = append(labels, label)
+			childHashes = append(childHashes, *child.hash.Load())
+		}
+		if hashNode(n.prefix, n.valueHash(hasher), labels, childHashes) != *n.hash.Load() {
+			return invalid("cached hash does not match the content")
+		}
+	}
+	return nil
+}
+
+// validate checks that the layout is consistent with the number of children.
+func (es *edges[T]) validate() error {
+	num := len(es.list)
+	switch es.layout() {
+	case layoutSorted:
+		if num > maxSorted {
+			return fmt.Errorf("%d edges in sorted layout", num)
+		}
+	case layout48:
+		if num > max48 {
+			return fmt.Errorf("%d edges in layout48", num)
+		}
+		var indexed int
+		for label, slot := range es.dense.index {
+			if slot == 0 {
+				continue
+			}
+			indexed++
+			if int(slot) > num || es.list[slot-1].label != byte(label) {
+				return fmt.Errorf("index of edge %#x points to invalid slot %d", label, slot)
+			}
+		}
+		if indexed != num {
+			return fmt.Errorf("%d edges indexed out of %d", indexed, num)
+		}
+	case layout256:
+		if num != endDense {
+			return fmt.Errorf("%d edges in layout256", num)
+		}
+		num = 0
+		for label, e := range es.list {
+			if e.node == nil {
+				continue
+			}
+			num++
+			if e.label != byte(label) {
+				return fmt.Errorf("edge %#x stored under %#x", e.label, label)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown layout %d", es.layout())
+	}
+	if es.layout() != layout256 {
+		for _, e := range es.list {
+			if e.node == nil {
+				return fmt.Errorf("edge %#x has no child", e.label)
+			}
+		}
+	}
+	if num != es.len() {
+		return fmt.Errorf("counter is %d but there are %d edges", es.len(), num)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package iradix
+
+import (
+	"testing"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	require.NoError(t, New[int]().Validate())
+	require.NoError(t, buildTree("a", "ab", "ac", "b", "").Validate())
+
+	hashed := buildTree("a", "ab", "ac", "b", "")
+	hashed.Hash(intCodec{}.Encode)
+	require.NoError(t, hashed.ValidateHashes(intCodec{}.Encode))
+
+	require.NoError(t, node("", false, leaf("a")).Validate())
+	require.NoError(t, node("", false, node("a", true, leaf("b"))).Validate())
+}
+
+func TestValidateInvalid(t *testing.T) {
+	dense := func() *Node[int] {
+		return buildTree("00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "0a", "0b", "0c", "0d", "0e",
+			"0f", "0g")
+	}
+
+	tests := []struct {
+		name string
+		root func() *Node[int]
+		err  string
+	}{
+		{
+			name: "leaf without value",
+			root: func() *Node[int] {
+				return node("", false, node("a", false))
+			},
+			err: `node "a": leaf without value`,
+		},
+		{
+			name: "single child",
+			root: func() *Node[int] {
+				return node("", false, node("a", false, leaf("b")))
+			},
+			err: `node "a": inner node without value has single child`,
+		},
+		{
+			name: "empty prefix",
+			root: func() *Node[int] {
+				root := node("", false, leaf("a"), leaf("b"))
+				root.edges.list[1].node.setPrefix(nil)
+				return root
+			},
+			err: `node "": edge 0x62 points to child with prefix ""`,
+		},
+		{
+			name: "label mismatch",
+			root: func() *Node[int] {
+				root := node("", false, leaf("a"), leaf("b"))
+				root.edges.list[1].label = 'c'
+				return root
+			},
+			err: `node "": edge 0x63 points to child with prefix "b"`,
+		},
+		{
+			name: "revision",
+			root: func() *Node[int] {
+				root := node("", false, leaf("a"))
+				root.edges.list[0].node.revision = 1
+				return root
+			},
+			err: `node "": child 0x61 has revision 1 greater than 0`,
+		},
+		{
+			name: "unsorted",
+			root: func() *Node[int] {
+				root := node("", false, leaf("a"), leaf("b"))
+				root.edges.list[0], root.edges.list[1] = root.edges.list[1], root.edges.list[0]
+				return root
+			},
+			err: `node "": edge 0x61 follows edge 0x62`,
+		},
+		{
+			name: "child not hashed",
+			root: func() *Node[int] {
+				root := node("", false, leaf("a"))
+				root.hash.Store(&Hash{})
+				return root
+			},
+			err: `node "": hash is cached but hash of child 0x61 is not`,
+		},
+		{
+			name: "counter",
+			root: func() *Node[int] {
+				root := dense()
+				root.edges.list[0].node.edges.dense.num++
+				return root
+			},
+			err: `node "0": counter is 18 but there are 17 edges`,
+		},
+		{
+			name: "missing indexed child",
+			root: func() *Node[int] {
+				root := dense()
+				es := &root.edges.list[0].node.edges
+				require.Equal(t, layout48, es.layout())
+				es.dense.index['g'] = 0
+				return root
+			},
+			err: `node "0": 16 edges indexed out of 17`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.root().Validate()
+			require.ErrorIs(t, err, ErrInvalidTree)
+			require.EqualError(t, err, test.err+": "+ErrInvalidTree.Error())
+		})
+	}
+}
+
+func TestValidateHashes(t *testing.T) {
+	root := buildTree("a", "ab", "ac", "b")
+	root.Hash(intCodec{}.Encode)
+	require.NoError(t, root.Validate())
+
+	*root.Get([]byte("ab")) = 10
+	require.NoError(t, root.Validate())
+	err := root.ValidateHashes(intCodec{}.Encode)
+	require.ErrorIs(t, err, ErrInvalidTree)
+	require.EqualError(t, err, `node "ab": cached hash does not match the content: `+ErrInvalidTree.Error())
+}
+
+func leaf(prefix string) *Node[int] {
+	n := &Node[int]{}
+	n.setValue(lo.ToPtr(1))
+	n.setPrefix([]byte(prefix))
+	return n
+}
+
+func node(prefix string, value bool, children ...*Node[int]) *Node[int] {
+	n := &Node[int]{}
+	n.setPrefix([]byte(prefix))
+	if value {
+		n.setValue(lo.ToPtr(1))
+	}
+	for _, child := range children {
+		n.addEdge(edge[int]{label: child.prefix[0], node: child}, nil)
+	}
+	return n
+}
@@ -0,0 +1,271 @@
+package iradix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"time"
+)
+
+// Log is a sequence of records, each one storing the changes of a single commit:
+//   - length  uint32 (length of the payload)
+//   - crc32   uint32 (Castagnoli, computed over the payload)
+//   - payload
+//
+// Payload is a sequence of operations:
+//   - kind   byte (walDelete or walInsert)
+//   - key    uvarint-prefixed bytes
+//   - value  uvarint-prefixed bytes (walInsert only)
+//
+// All the integers are little endian.
+
+const (
+	walRecordHeaderSize      = 8
+	walDelete           byte = 0
+	walInsert           byte = 1
+)
+
+// SyncPolicy defines when the log is synced to the disk.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs the log on every commit.
+	SyncAlways SyncPolicy = iota
+
+	// SyncPeriodic syncs the log on commit if SyncInterval elapsed since the previous sync.
+	SyncPeriodic
+
+	// SyncNever leaves syncing to the operating system.
+	SyncNever
+)
+
+// walFile is the file the log is stored in.
+type walFile interface {
+	io.ReadWriteSeeker
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// wal is the write-ahead log of the changes committed to the store.
+type wal[T any] struct {
+	file     walFile
+	codec    ValueCodec[T]
+	ops      opEncoder[T]
+	policy   SyncPolicy
+	interval time.Duration
+	lastSync time.Time
+	size     int64
+	buf      []byte
+
+	// err is set if the log can't be restored to the last record after failed append, so appending
+	// to it is not safe anymore.
+	err error
+}
+
+// openWAL opens the log and replays it on top of root. Torn or corrupted record at the end of the log,
+// caused by a crash during write, is truncated.
+func openWAL[T any](path string, codec ValueCodec[T], config StoreConfig, root *Node[T]) (*wal[T], *Node[T], error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &wal[T]{
+		file:     f,
+		codec:    codec,
+		ops:      opEncoder[T]{codec: codec},
+		policy:   config.Sync,
+		interval: config.SyncInterval,
+		lastSync: time.Now(),
+	}
+
+	root, err = w.replay(root)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+	return w, root, nil
+}
+
+func (w *wal[T]) replay(root *Node[T]) (*Node[T], error) {
+	data, err := io.ReadAll(w.file)
+	if err != nil {
+		return nil, err
+	}
+
+	var offset int
+	for len(data)-offset >= walRecordHeaderSize {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		start := offset + walRecordHeaderSize
+		if len(data)-start < size {
+			break
+		}
+		payload := data[start : start+size]
+		if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(data[offset+4:]) {
+			break
+		}
+		txn := NewTxn(root)
+		if err := applyOps(txn, w.codec, payload); err != nil {
+			return nil, err
+		}
+		root = txn.Commit()
+		offset = start + size
+	}
+
+	w.size = int64(offset)
+	if offset != len(data) {
+		if err := w.file.Truncate(w.size); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := w.file.Seek(w.size, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// append stores the changes transforming oldRoot into newRoot in the log. On error, the record is removed,
+// so the commit is not replayed later. If the record can't be removed, log is marked as broken and all
+// the following appends fail.
+func (w *wal[T]) append(oldRoot, newRoot *Node[T]) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	b := append(w.buf[:0], make([]byte, walRecordHeaderSize)...)
+	_ = diff(oldRoot, newRoot, func(k []byte, _, newV *T) error {
+		b = w.ops.append(b, k, newV)
+		return nil
+	})
+	w.buf = b
+
+	payload := b[walRecordHeaderSize:]
+	if len(payload) == 0 {
+		return nil
+	}
+	binary.LittleEndian.PutUint32(b, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(b[4:], crc32.Checksum(payload, crcTable))
+
+	if err := w.write(b); err != nil {
+		if err2 := w.rewind(); err2 != nil {
+			w.err = fmt.Errorf("restoring log after failed append failed: %w", errors.Join(err, err2))
+			return w.err
+		}
+		return err
+	}
+	w.size += int64(len(b))
+	return nil
+}
+
+// write writes the record and syncs the log according to the policy.
+func (w *wal[T]) write(b []byte) error {
+	if _, err := w.file.Write(b); err != nil {
+		return err
+	}
+
+	switch w.policy {
+	case SyncAlways:
+		return w.sync()
+	case SyncPeriodic:
+		if time.Since(w.lastSync) >= w.interval {
+			return w.sync()
+		}
+	case SyncNever:
+	}
+	return nil
+}
+
+func (w *wal[T]) sync() error {
+	if err := w.file.Sync(); err != nil {
+		return err
+	}
+	w.lastSync = time.Now()
+	return nil
+}
+
+// truncate removes all the records from the log.
+func (w *wal[T]) truncate() error {
+	w.size = 0
+	if err := w.rewind(); err != nil {
+		return err
+	}
+	return w.sync()
+}
+
+func (w *wal[T]) rewind() error {
+	if err := w.file.Truncate(w.size); err != nil {
+		return err
+	}
+	_, err := w.file.Seek(w.size, io.SeekStart)
+	return err
+}
+
+func (w *wal[T]) close() error {
+	return errors.Join(w.file.Sync(), w.file.Close())
+}
+
+// opEncoder encodes the operations stored in the log.
+type opEncoder[T any] struct {
+	codec    ValueCodec[T]
+	valueBuf []byte
+}
+
+// append appends the operation setting key to the value. Nil value means deletion.
+func (e *opEncoder[T]) append(b, k []byte, v *T) []byte {
+	if v == nil {
+		b = append(b, walDelete)
+		b = binary.AppendUvarint(b, uint64(len(k)))
+		return append(b, k...)
+	}
+
+	e.valueBuf = e.codec.Encode(e.valueBuf[:0], v)
+	b = append(b, walInsert)
+	b = binary.AppendUvarint(b, uint64(len(k)))
+	b = append(b, k...)
+	b = binary.AppendUvarint(b, uint64(len(e.valueBuf)))
+	return append(b, e.valueBuf...)
+}
+
+// applyOps applies operations encoded by opEncoder to the transaction.
+func applyOps[T any](txn *Txn[T], codec ValueCodec[T], payload []byte) error {
+	for len(payload) > 0 {
+		kind := payload[0]
+		payload = payload[1:]
+		k, n := walChunk(payload)
+		if n <= 0 {
+			return fmt.Errorf("invalid key in the log: %w", ErrCorrupted)
+		}
+		payload = payload[n:]
+
+		switch kind {
+		case walDelete:
+			txn.Delete(k)
+		case walInsert:
+			data, n := walChunk(payload)
+			if n <= 0 {
+				return fmt.Errorf("invalid value in the log: %w", ErrCorrupted)
+			}
+			payload = payload[n:]
+			v, err := codec.Decode(data)
+			if err != nil {
+				return fmt.Errorf("decoding value of key %q failed: %w", k, err)
+			}
+			txn.Insert(k, v)
+		default:
+			return fmt.Errorf("invalid operation %d in the log: %w", kind, ErrCorrupted)
+		}
+	}
+	return nil
+}
+
+func walChunk(data []byte) ([]byte, int) {
+	size, n := binary.Uvarint(data)
+	if n <= 0 || uint64(len(data)-n) < size {
+		return nil, -1
+	}
+	end := n + int(size)
+	return data[n:end], end
+}